@@ -0,0 +1,284 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Certificate is a generated certificate with its private key.
+type Certificate struct {
+	// Certificate is the parsed certificate.
+	Certificate *x509.Certificate
+	// PrivateKey is the private key belonging to the certificate.
+	PrivateKey crypto.Signer
+	// chain contains the intermediate certificates between this certificate and the root CA.
+	chain []*x509.Certificate
+}
+
+// CertificatePEM returns the certificate in PEM format.
+func (c *Certificate) CertificatePEM() string {
+	return encodeCertificates(c.Certificate)
+}
+
+// ChainPEM returns the certificate followed by the intermediate certificates that issued it, in PEM format. Use this
+// instead of CertificatePEM for certificates issued by an intermediate CA.
+func (c *Certificate) ChainPEM() string {
+	return encodeCertificates(append([]*x509.Certificate{c.Certificate}, c.chain...)...)
+}
+
+// PrivateKeyPEM returns the private key in PKCS #8 PEM format.
+func (c *Certificate) PrivateKeyPEM() string {
+	der, err := x509.MarshalPKCS8PrivateKey(c.PrivateKey)
+	if err != nil {
+		// All generated key types can be marshalled.
+		panic(fmt.Errorf("BUG: failed to marshal private key (%w)", err))
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// EncryptedPrivateKeyPEM returns the private key in encrypted PKCS #8 PEM format, using PBES2 with AES-256-CBC and a
+// key derived from the passphrase with PBKDF2.
+func (c *Certificate) EncryptedPrivateKeyPEM(passphrase string) (string, error) {
+	der, err := pkcs8.MarshalPrivateKey(c.PrivateKey, []byte(passphrase), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt private key (%w)", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der})), nil
+}
+
+// PKCS12 returns a PKCS #12 (PFX) bundle containing the certificate, the intermediate certificates that issued it
+// and the private key, encrypted with the passphrase.
+func (c *Certificate) PKCS12(passphrase string) ([]byte, error) {
+	pfx, err := pkcs12.Modern.Encode(c.PrivateKey, c.Certificate, c.chain, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PKCS #12 bundle (%w)", err)
+	}
+	return pfx, nil
+}
+
+// CA is a certificate authority that issues certificates and keeps track of revoked ones.
+type CA struct {
+	Certificate
+
+	lock      *sync.Mutex
+	revoked   []x509.RevocationListEntry
+	crlNumber int64
+}
+
+// NewCA creates a self-signed root CA.
+func NewCA(opts ...Option) (*CA, error) {
+	o := newOptions("ContainerSSH Test CA", nil, nil, opts)
+	key, err := generateKey(o)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newTemplate(o, key)
+	if err != nil {
+		return nil, err
+	}
+	makeCA(template)
+	cert, err := sign(template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate (%w)", err)
+	}
+	return newCA(Certificate{Certificate: cert, PrivateKey: key}), nil
+}
+
+// NewIntermediate creates an intermediate CA signed by this CA.
+func (ca *CA) NewIntermediate(opts ...Option) (*CA, error) {
+	o := newOptions("ContainerSSH Test Intermediate CA", nil, nil, opts)
+	template, key, err := ca.newLeafTemplate(o)
+	if err != nil {
+		return nil, err
+	}
+	makeCA(template)
+	cert, err := sign(template, ca.Certificate.Certificate, key.Public(), ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create intermediate CA certificate (%w)", err)
+	}
+	return newCA(Certificate{Certificate: cert, PrivateKey: key, chain: ca.issuerChain()}), nil
+}
+
+// NewServerCert creates a certificate for server authentication. Unless DNS names or IP addresses are set, the
+// certificate is valid for localhost, 127.0.0.1 and ::1.
+func (ca *CA) NewServerCert(opts ...Option) (*Certificate, error) {
+	o := newOptions(
+		"localhost",
+		[]string{"localhost"},
+		[]net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		opts,
+	)
+	return ca.newLeaf(o, x509.ExtKeyUsageServerAuth)
+}
+
+// NewClientCert creates a certificate for client authentication.
+func (ca *CA) NewClientCert(opts ...Option) (*Certificate, error) {
+	o := newOptions("ContainerSSH Test Client", nil, nil, opts)
+	return ca.newLeaf(o, x509.ExtKeyUsageClientAuth)
+}
+
+// Revoke adds the certificate to the revocation list of the CA. The certificate must have been issued by this CA.
+func (ca *CA) Revoke(cert *Certificate) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.revoked = append(ca.revoked, x509.RevocationListEntry{
+		SerialNumber:   cert.Certificate.SerialNumber,
+		RevocationTime: time.Now(),
+	})
+}
+
+// CRLPEM returns a certificate revocation list signed by the CA containing all revoked certificates, valid for 24
+// hours, in PEM format.
+func (ca *CA) CRLPEM() (string, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.crlNumber++
+	now := time.Now()
+	der, err := x509.CreateRevocationList(
+		rand.Reader,
+		&x509.RevocationList{
+			Number:                    big.NewInt(ca.crlNumber),
+			ThisUpdate:                now.Add(-time.Minute),
+			NextUpdate:                now.Add(24 * time.Hour),
+			RevokedCertificateEntries: ca.revoked,
+		},
+		ca.Certificate.Certificate,
+		ca.PrivateKey,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create CRL (%w)", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})), nil
+}
+
+func newCA(cert Certificate) *CA {
+	return &CA{
+		Certificate: cert,
+		lock:        &sync.Mutex{},
+	}
+}
+
+// issuerChain returns the chain to include with certificates issued by this CA. Root CAs are not included.
+func (ca *CA) issuerChain() []*x509.Certificate {
+	if isSelfSigned(ca.Certificate.Certificate) {
+		return nil
+	}
+	return append([]*x509.Certificate{ca.Certificate.Certificate}, ca.chain...)
+}
+
+func (ca *CA) newLeafTemplate(o *options) (*x509.Certificate, crypto.Signer, error) {
+	key, err := generateKey(o)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := newTemplate(o, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return template, key, nil
+}
+
+func (ca *CA) newLeaf(o *options, usage x509.ExtKeyUsage) (*Certificate, error) {
+	template, key, err := ca.newLeafTemplate(o)
+	if err != nil {
+		return nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	cert, err := sign(template, ca.Certificate.Certificate, key.Public(), ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate (%w)", err)
+	}
+	return &Certificate{Certificate: cert, PrivateKey: key, chain: ca.issuerChain()}, nil
+}
+
+func generateKey(o *options) (crypto.Signer, error) {
+	switch o.keyType {
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, o.rsaKeySize)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", o.keyType)
+	}
+}
+
+func newTemplate(o *options, key crypto.Signer) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number (%w)", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key (%w)", err)
+	}
+	subjectKeyID := sha1.Sum(publicKey)
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   o.commonName,
+			Organization: []string{o.organization},
+		},
+		DNSNames:     o.dnsNames,
+		IPAddresses:  o.ipAddresses,
+		NotBefore:    o.notBefore,
+		NotAfter:     o.notAfter,
+		SubjectKeyId: subjectKeyID[:],
+	}, nil
+}
+
+func makeCA(template *x509.Certificate) {
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	template.DNSNames = nil
+	template.IPAddresses = nil
+}
+
+func sign(
+	template *x509.Certificate,
+	parent *x509.Certificate,
+	publicKey crypto.PublicKey,
+	signer crypto.Signer,
+) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(cert) == nil
+}
+
+func encodeCertificates(certs ...*x509.Certificate) string {
+	result := strings.Builder{}
+	for _, cert := range certs {
+		_ = pem.Encode(&result, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return result.String()
+}
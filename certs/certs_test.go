@@ -0,0 +1,127 @@
+package certs_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/containerssh/http/certs"
+)
+
+func TestChain(t *testing.T) {
+	for _, keyType := range []certs.KeyType{certs.KeyTypeECDSA, certs.KeyTypeRSA, certs.KeyTypeEd25519} {
+		t.Run(string(keyType), func(t *testing.T) {
+			ca, err := certs.NewCA(certs.WithKeyType(keyType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			intermediate, err := ca.NewIntermediate(certs.WithKeyType(keyType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := intermediate.NewServerCert(
+				certs.WithKeyType(keyType),
+				certs.WithDNSNames("example.com"),
+				certs.WithIPAddresses(net.IPv4(192, 0, 2, 1)),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := ca.NewClientCert(certs.WithKeyType(keyType))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM([]byte(ca.CertificatePEM())) {
+				t.Fatal("failed to parse CA PEM")
+			}
+			keyPair, err := tls.X509KeyPair([]byte(server.ChainPEM()), []byte(server.PrivateKeyPEM()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keyPair.Certificate) != 2 {
+				t.Fatalf("unexpected chain length: %d", len(keyPair.Certificate))
+			}
+			intermediates := x509.NewCertPool()
+			intermediates.AddCert(intermediate.Certificate.Certificate)
+			if _, err := server.Certificate.Verify(x509.VerifyOptions{
+				DNSName:       "example.com",
+				Roots:         roots,
+				Intermediates: intermediates,
+			}); err != nil {
+				t.Fatalf("server certificate does not verify (%v)", err)
+			}
+			if err := server.Certificate.VerifyHostname("192.0.2.1"); err != nil {
+				t.Fatalf("server certificate is not valid for its IP address (%v)", err)
+			}
+			if err := server.Certificate.VerifyHostname("localhost"); err == nil {
+				t.Fatalf("server certificate contains the default names in addition to the configured ones")
+			}
+			if _, err := client.Certificate.Verify(x509.VerifyOptions{
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			}); err != nil {
+				t.Fatalf("client certificate does not verify (%v)", err)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.NewServerCert(certs.WithValidity(time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate.Certificate)
+	if _, err := server.Certificate.Verify(x509.VerifyOptions{Roots: roots}); err == nil {
+		t.Fatalf("expired certificate verified")
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := ca.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := ca.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.Revoke(revoked)
+
+	crlPEM, err := ca.CRLPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(crlPEM))
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("invalid CRL PEM")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(ca.Certificate.Certificate); err != nil {
+		t.Fatalf("CRL signature does not verify (%v)", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("unexpected number of revoked certificates: %d", len(crl.RevokedCertificateEntries))
+	}
+	serial := crl.RevokedCertificateEntries[0].SerialNumber
+	if serial.Cmp(revoked.Certificate.SerialNumber) != 0 || serial.Cmp(valid.Certificate.SerialNumber) == 0 {
+		t.Fatalf("wrong certificate on the CRL")
+	}
+}
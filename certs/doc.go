@@ -0,0 +1,4 @@
+// Package certs creates certificate authorities and certificates for testing TLS and mutual TLS. All certificates and
+// keys are available in PEM format, which can be used directly in the CACert, ClientCert and ClientKey fields of the
+// client configuration and the Cert, Key and ClientCACert fields of the server configuration.
+package certs
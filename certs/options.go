@@ -0,0 +1,105 @@
+package certs
+
+import (
+	"net"
+	"time"
+)
+
+// KeyType is the type of the private key to generate.
+type KeyType string
+
+const (
+	// KeyTypeECDSA generates an ECDSA key on the P-256 curve. This is the default.
+	KeyTypeECDSA KeyType = "ecdsa"
+	// KeyTypeRSA generates a 2048 bit RSA key.
+	KeyTypeRSA KeyType = "rsa"
+	// KeyTypeEd25519 generates an Ed25519 key.
+	KeyTypeEd25519 KeyType = "ed25519"
+)
+
+// Option changes the properties of a generated certificate.
+type Option func(*options)
+
+type options struct {
+	keyType      KeyType
+	rsaKeySize   int
+	commonName   string
+	organization string
+	dnsNames     []string
+	ipAddresses  []net.IP
+	notBefore    time.Time
+	notAfter     time.Time
+}
+
+func newOptions(commonName string, defaultDNSNames []string, defaultIPs []net.IP, opts []Option) *options {
+	now := time.Now()
+	result := &options{
+		keyType:      KeyTypeECDSA,
+		rsaKeySize:   2048,
+		commonName:   commonName,
+		organization: "ContainerSSH Test",
+		notBefore:    now.Add(-time.Minute),
+		notAfter:     now.Add(24 * time.Hour),
+	}
+	for _, opt := range opts {
+		opt(result)
+	}
+	if result.dnsNames == nil && result.ipAddresses == nil {
+		result.dnsNames = defaultDNSNames
+		result.ipAddresses = defaultIPs
+	}
+	return result
+}
+
+// WithKeyType sets the type of the generated private key.
+func WithKeyType(keyType KeyType) Option {
+	return func(o *options) {
+		o.keyType = keyType
+	}
+}
+
+// WithRSAKeySize sets the size of generated RSA keys in bits. Defaults to 2048.
+func WithRSAKeySize(bits int) Option {
+	return func(o *options) {
+		o.rsaKeySize = bits
+	}
+}
+
+// WithCommonName sets the common name of the certificate subject.
+func WithCommonName(commonName string) Option {
+	return func(o *options) {
+		o.commonName = commonName
+	}
+}
+
+// WithOrganization sets the organization of the certificate subject.
+func WithOrganization(organization string) Option {
+	return func(o *options) {
+		o.organization = organization
+	}
+}
+
+// WithDNSNames adds DNS names to the subject alternative names. Setting any DNS name or IP address replaces the
+// default names of server certificates.
+func WithDNSNames(names ...string) Option {
+	return func(o *options) {
+		o.dnsNames = append(o.dnsNames, names...)
+	}
+}
+
+// WithIPAddresses adds IP addresses to the subject alternative names. Setting any DNS name or IP address replaces
+// the default names of server certificates.
+func WithIPAddresses(ips ...net.IP) Option {
+	return func(o *options) {
+		o.ipAddresses = append(o.ipAddresses, ips...)
+	}
+}
+
+// WithValidity sets the validity period of the certificate. It can be used to create expired or not yet valid
+// certificates. Defaults to one minute ago until 24 hours from now.
+func WithValidity(notBefore time.Time, notAfter time.Time) Option {
+	return func(o *options) {
+		o.notBefore = notBefore
+		o.notAfter = notAfter
+	}
+}
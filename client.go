@@ -1,13 +1,22 @@
 package http
 
+import (
+	"context"
+)
+
 // Client is a simplified HTTP interface that ensures that a struct is transported to a remote endpoint
-// properly encoded, and the response is decoded into the response struct.
+// properly encoded, and the response is decoded into the response struct. All methods accept RequestOption values
+// to change the headers, query, timeout or expected status codes of a single request.
 type Client interface {
+	// Request queries the configured endpoint with the specified method and path, sending the requestBody and
+	// providing the response in the responseBody structure. For GET, HEAD and DELETE requests the requestBody is
+	// encoded into the query string instead. It returns the HTTP status code and any potential errors.
 	Request(
 		Method string,
 		path string,
 		requestBody interface{},
 		responseBody interface{},
+		options ...RequestOption,
 	) (statusCode int, err error)
 
 	// Get queries the configured endpoint with the path providing the response in the responseBody structure. It
@@ -15,6 +24,14 @@ type Client interface {
 	Get(
 		path string,
 		responseBody interface{},
+		options ...RequestOption,
+	) (statusCode int, err error)
+
+	// Head queries the configured endpoint with the path using a HEAD request. It returns the HTTP status code and
+	// any potential errors.
+	Head(
+		path string,
+		options ...RequestOption,
 	) (statusCode int, err error)
 
 	// Post queries the configured endpoint with the path, sending the requestBody and providing the
@@ -23,6 +40,7 @@ type Client interface {
 		path string,
 		requestBody interface{},
 		responseBody interface{},
+		options ...RequestOption,
 	) (statusCode int, err error)
 
 	// Put queries the configured endpoint with the path, sending the requestBody and providing the
@@ -31,6 +49,7 @@ type Client interface {
 		path string,
 		requestBody interface{},
 		responseBody interface{},
+		options ...RequestOption,
 	) (statusCode int, err error)
 
 	// Patch queries the configured endpoint with the path, sending the requestBody and providing the
@@ -39,13 +58,33 @@ type Client interface {
 		path string,
 		requestBody interface{},
 		responseBody interface{},
+		options ...RequestOption,
 	) (statusCode int, err error)
 
-	// Delete queries the configured endpoint with the path, sending the requestBody and providing the
-	// response in the responseBody structure. It returns the HTTP status code and any potential errors.
+	// Delete queries the configured endpoint with the path, encoding the requestBody into the query string and
+	// providing the response in the responseBody structure. It returns the HTTP status code and any potential errors.
 	Delete(
 		path string,
 		requestBody interface{},
 		responseBody interface{},
+		options ...RequestOption,
 	) (statusCode int, err error)
+
+	// Subscribe connects to a Server-Sent Events stream at the path and calls onEvent for each event until the
+	// context is canceled. If newValue is not nil, it is called with the event type and the JSON data of the event is
+	// decoded into the returned pointer. Lost connections and 5xx responses lead to a reconnect, sending the last
+	// event ID in the Last-Event-ID header and waiting for the retry interval sent by the server. It returns nil if
+	// the context is canceled or the server responds with 204 No Content, and an error if the stream cannot be used.
+	Subscribe(
+		ctx context.Context,
+		path string,
+		newValue func(eventType string) interface{},
+		onEvent func(event Event),
+		options ...RequestOption,
+	) error
+
+	// HealthCheck checks if the server can be reached. It resolves the host name, connects, performs the TLS
+	// handshake and sends the probe request from the health check configuration as separate stages, stopping at the
+	// first failure. The returned report contains the result of each stage.
+	HealthCheck(ctx context.Context) HealthReport
 }
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containerssh/log"
+	"golang.org/x/net/publicsuffix"
+)
+
+// persistedCookie is the on-disk format of a cookie in the cookie jar file.
+type persistedCookie struct {
+	// URL is the URL the cookie was received from.
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  time.Time     `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+}
+
+// cookieJar is an in-memory cookie jar that optionally persists all cookies to a file so they survive restarts.
+type cookieJar struct {
+	jar     *cookiejar.Jar
+	file    string
+	lock    *sync.Mutex
+	cookies map[string]persistedCookie
+	logger  log.Logger
+}
+
+// newCookieJar creates a cookie jar using the public suffix list for domain matching. If a file is configured,
+// cookies are loaded from it.
+func newCookieJar(config CookieJarConfiguration, logger log.Logger) (*cookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	result := &cookieJar{
+		jar:     jar,
+		file:    config.File,
+		lock:    &sync.Mutex{},
+		cookies: map[string]persistedCookie{},
+		logger:  logger,
+	}
+	if config.File != "" {
+		if err := result.load(); err != nil {
+			return nil, fmt.Errorf("failed to load cookie jar from %s (%w)", config.File, err)
+		}
+	}
+	return result, nil
+}
+
+func (c *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.jar.SetCookies(u, cookies)
+	if c.file == "" {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	for _, cookie := range cookies {
+		key := fmt.Sprintf("%s;%s;%s;%s", u.Hostname(), cookie.Domain, cookie.Path, cookie.Name)
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if cookie.MaxAge < 0 || (!expires.IsZero() && !expires.After(now)) {
+			delete(c.cookies, key)
+			continue
+		}
+		c.cookies[key] = persistedCookie{
+			URL:      u.String(),
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+			SameSite: cookie.SameSite,
+		}
+	}
+	if err := c.save(); err != nil {
+		c.logger.Warning(log.Wrap(err, EClientCookieJarSaveFailed, "Failed to save cookie jar to %s", c.file))
+	}
+}
+
+func (c *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return c.jar.Cookies(u)
+}
+
+// load reads the cookies from the file. A missing file is not an error.
+func (c *cookieJar) load() error {
+	data, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var cookies map[string]persistedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return err
+	}
+	now := time.Now()
+	for key, cookie := range cookies {
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+		u, err := url.Parse(cookie.URL)
+		if err != nil {
+			return fmt.Errorf("invalid URL for cookie %s (%w)", cookie.Name, err)
+		}
+		c.jar.SetCookies(u, []*http.Cookie{
+			{
+				Name:     cookie.Name,
+				Value:    cookie.Value,
+				Domain:   cookie.Domain,
+				Path:     cookie.Path,
+				Expires:  cookie.Expires,
+				Secure:   cookie.Secure,
+				HttpOnly: cookie.HttpOnly,
+				SameSite: cookie.SameSite,
+			},
+		})
+		c.cookies[key] = cookie
+	}
+	return nil
+}
+
+// save writes the cookies to the file.
+func (c *cookieJar) save() error {
+	data, err := json.Marshal(c.cookies)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.file, data)
+}
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/containerssh/log"
+)
+
+// redactedValue replaces the values of redacted headers and fields in debug logs and fixture files.
+const redactedValue = "[redacted]"
+
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var defaultRedactFields = []string{"password", "authorization", "privateKey", "token", "secret"}
+
+// debugLogger logs request and response headers and bodies with the configured redaction rules applied.
+type debugLogger struct {
+	maxBodyLength int
+	headers       map[string]bool
+	fields        []string
+}
+
+func newDebugLogger(config DebugConfiguration) *debugLogger {
+	maxBodyLength := config.MaxBodyLength
+	if maxBodyLength == 0 {
+		maxBodyLength = 4096
+	}
+	redactFields := config.RedactFields
+	if len(redactFields) == 0 {
+		redactFields = defaultRedactFields
+	}
+	fields := make([]string, len(redactFields))
+	for i, field := range redactFields {
+		fields[i] = strings.ToLower(field)
+	}
+	return &debugLogger{
+		maxBodyLength: maxBodyLength,
+		headers:       redactedHeaders(config),
+		fields:        fields,
+	}
+}
+
+func (d *debugLogger) logRequest(logger log.Logger, req *http.Request, body []byte) {
+	headers := d.redactHeaders(req.Header)
+	loggedBody := d.redactBody(req.Header.Get("Content-Type"), body)
+	logger.Debug(log.NewMessage(
+		MClientRequestDebug,
+		"HTTP %s request to %s, headers: %v, body: %s",
+		req.Method,
+		d.redactURL(req.URL),
+		headers,
+		loggedBody,
+	).Label("headers", headers).Label("body", loggedBody))
+}
+
+func (d *debugLogger) logResponse(logger log.Logger, resp *http.Response, body []byte) {
+	headers := d.redactHeaders(resp.Header)
+	loggedBody := d.redactBody(resp.Header.Get("Content-Type"), body)
+	logger.Debug(log.NewMessage(
+		MClientResponseDebug,
+		"HTTP response with status %d, headers: %v, body: %s",
+		resp.StatusCode,
+		headers,
+		loggedBody,
+	).Label("statusCode", resp.StatusCode).Label("headers", headers).Label("body", loggedBody))
+}
+
+// redactURL returns the URL for logging with the password and the values of redacted query parameters replaced.
+func (d *debugLogger) redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	redacted := *u
+	// Parameters that fail to parse are dropped, since they may still contain sensitive values.
+	query, _ := url.ParseQuery(u.RawQuery)
+	for name := range query {
+		if d.isRedactedField(name, name) {
+			query[name] = []string{redactedValue}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.Redacted()
+}
+
+// redactedHeaders returns the canonical names of the headers whose values are redacted by the configuration. Fixture
+// recording uses the same headers, so credentials are not written to fixture files either.
+func redactedHeaders(config DebugConfiguration) map[string]bool {
+	names := config.RedactHeaders
+	if len(names) == 0 {
+		names = defaultRedactHeaders
+	}
+	headers := make(map[string]bool, len(names))
+	for _, name := range names {
+		headers[http.CanonicalHeaderKey(name)] = true
+	}
+	return headers
+}
+
+func (d *debugLogger) redactHeaders(header http.Header) map[string][]string {
+	return redactHeaders(header, d.headers)
+}
+
+// redactHeaders returns a copy of the header with the values of the redacted headers replaced.
+func redactHeaders(header http.Header, redacted map[string]bool) map[string][]string {
+	result := make(map[string][]string, len(header))
+	for name, values := range header {
+		if redacted[http.CanonicalHeaderKey(name)] {
+			result[name] = []string{redactedValue}
+		} else {
+			result[name] = values
+		}
+	}
+	return result
+}
+
+// redactBody returns the body as a string for logging. JSON and form bodies have the redaction rules applied, other
+// bodies are only described by their size and type since they cannot be redacted.
+func (d *debugLogger) redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType := bodyMediaType(contentType)
+	redacted, err := d.redactFields(mediaType, body)
+	switch {
+	case err == nil:
+	case isJSONMediaType(mediaType):
+		return fmt.Sprintf("[%d bytes of invalid JSON]", len(body))
+	case mediaType == "application/x-www-form-urlencoded":
+		return fmt.Sprintf("[%d bytes of invalid form data]", len(body))
+	default:
+		return fmt.Sprintf("[%d bytes of %s]", len(body), mediaType)
+	}
+	result := string(redacted)
+	if len(result) > d.maxBodyLength {
+		// Cutting inside a multi-byte character would produce invalid UTF-8 in the log.
+		end := d.maxBodyLength
+		for end > 0 && !utf8.RuneStart(result[end]) {
+			end--
+		}
+		return result[:end] + fmt.Sprintf("... [truncated, %d bytes total]", len(result))
+	}
+	return result
+}
+
+// bodyMediaType returns the media type of a body with the Content-Type. Bodies without a Content-Type are JSON.
+func bodyMediaType(contentType string) string {
+	if contentType == "" {
+		return "application/json"
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// redactFields returns the JSON or form body with the values of the redacted fields replaced. It returns an error if
+// the body has a different type or cannot be parsed, since it cannot be redacted then.
+func (d *debugLogger) redactFields(mediaType string, body []byte) ([]byte, error) {
+	switch {
+	case isJSONMediaType(mediaType):
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("invalid JSON")
+		}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		// Numbers are kept as they were sent instead of being converted to floating point.
+		decoder.UseNumber()
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		return json.Marshal(d.redactJSON(decoded, ""))
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for name := range form {
+			if d.isRedactedField(name, name) {
+				form[name] = []string{redactedValue}
+			}
+		}
+		return []byte(form.Encode()), nil
+	default:
+		return nil, fmt.Errorf("%s bodies cannot be redacted", mediaType)
+	}
+}
+
+// redactJSON replaces the values of redacted fields in the decoded JSON document. The path is the dot-separated path
+// of the value from the document root, array indexes are not part of the path.
+func (d *debugLogger) redactJSON(value interface{}, path string) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typedValue {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			if d.isRedactedField(key, itemPath) {
+				typedValue[key] = redactedValue
+			} else {
+				typedValue[key] = d.redactJSON(item, itemPath)
+			}
+		}
+	case []interface{}:
+		for i, item := range typedValue {
+			typedValue[i] = d.redactJSON(item, path)
+		}
+	}
+	return value
+}
+
+// isRedactedField returns true if a rule matches the field. Rules containing a dot match the full path of the field,
+// other rules match the field name at any depth. Matching is case-insensitive.
+func (d *debugLogger) isRedactedField(name string, path string) bool {
+	name = strings.ToLower(name)
+	path = strings.ToLower(path)
+	for _, field := range d.fields {
+		if strings.Contains(field, ".") {
+			if field == path {
+				return true
+			}
+		} else if field == name {
+			return true
+		}
+	}
+	return false
+}
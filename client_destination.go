@@ -0,0 +1,162 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/containerssh/log"
+)
+
+// uniqueLocalPrefix contains the IPv6 unique local addresses, which are used for internal services such as the
+// fd00:ec2::254 metadata endpoint.
+var uniqueLocalPrefix = netip.MustParsePrefix("fc00::/7")
+
+// embeddedIPv4Prefixes contain IPv6 addresses that are translated to the IPv4 address in their last 32 bits, so they
+// are checked like that IPv4 address. IPv4-mapped addresses (::ffff:0:0/96) are converted before the check.
+var embeddedIPv4Prefixes = []netip.Prefix{
+	// IPv4-compatible addresses, deprecated but still routed by some stacks.
+	netip.MustParsePrefix("::/96"),
+	// IPv4-translated addresses.
+	netip.MustParsePrefix("::ffff:0:0:0/96"),
+	// The NAT64 well-known prefix.
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// destinationChecker enforces the DestinationPolicy on outgoing connections.
+type destinationChecker struct {
+	allow            []netip.Prefix
+	deny             []netip.Prefix
+	allowLoopback    bool
+	allowLinkLocal   bool
+	allowUniqueLocal bool
+}
+
+func newDestinationChecker(policy DestinationPolicy) (*destinationChecker, error) {
+	allow, err := parsePrefixes(policy.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow list (%w)", err)
+	}
+	deny, err := parsePrefixes(policy.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny list (%w)", err)
+	}
+	return &destinationChecker{
+		allow:            allow,
+		deny:             deny,
+		allowLoopback:    policy.AllowLoopback,
+		allowLinkLocal:   policy.AllowLinkLocal,
+		allowUniqueLocal: policy.AllowUniqueLocal,
+	}, nil
+}
+
+// parsePrefixes parses a list of CIDRs. Single IP addresses are accepted as a prefix containing only that address.
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR: %s", cidr)
+			}
+			result = append(result, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR: %s", cidr)
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedIPv4 returns the IPv4 address embedded in an IPv4-compatible, IPv4-translated or NAT64 address.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() || addr.IsUnspecified() || addr.IsLoopback() || !containsAddr(embeddedIPv4Prefixes, addr) {
+		return netip.Addr{}, false
+	}
+	bytes := addr.As16()
+	return netip.AddrFrom4([4]byte{bytes[12], bytes[13], bytes[14], bytes[15]}), true
+}
+
+// check returns an error if connecting to the IP address is not allowed. The deny list takes precedence over
+// everything else. Addresses on the allow list may be loopback, link-local or unique local addresses even if those
+// are not allowed otherwise. IPv6 addresses embedding an IPv4 address must pass the check for both addresses.
+func (d *destinationChecker) check(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if embedded, ok := embeddedIPv4(addr); ok {
+		if err := d.check(embedded); err != nil {
+			return fmt.Errorf("%s embeds %w", addr, err)
+		}
+	}
+	if containsAddr(d.deny, addr) {
+		return fmt.Errorf("%s is on the deny list", addr)
+	}
+	if containsAddr(d.allow, addr) {
+		return nil
+	}
+	if len(d.allow) > 0 {
+		return fmt.Errorf("%s is not on the allow list", addr)
+	}
+	if !d.allowLoopback && (addr.IsLoopback() || addr.IsUnspecified()) {
+		return fmt.Errorf("%s is a loopback address", addr)
+	}
+	if !d.allowLinkLocal && (addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast()) {
+		return fmt.Errorf("%s is a link-local address", addr)
+	}
+	if !d.allowUniqueLocal && uniqueLocalPrefix.Contains(addr) {
+		return fmt.Errorf("%s is a unique local address", addr)
+	}
+	return nil
+}
+
+// control is used as the Control function of the dialer, which is called with the resolved IP address right before
+// each connection is made, including connections for redirects.
+func (d *destinationChecker) control(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if err := d.check(addr); err != nil {
+		return log.Wrap(err, EClientDestinationDenied, "Connection to %s denied by the destination policy", address).
+			Label("destination", address)
+	}
+	return nil
+}
+
+// checkHost resolves the host name and checks all addresses it resolves to. It is used to reject redirects before
+// they are followed. The dialer still checks the address actually connected to, as DNS answers may change.
+func (d *destinationChecker) checkHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return d.check(addr)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// Resolution errors are reported when the connection is made.
+		return nil
+	}
+	for _, addr := range addrs {
+		if err := d.check(addr); err != nil {
+			return fmt.Errorf("%s resolves to %w", host, err)
+		}
+	}
+	return nil
+}
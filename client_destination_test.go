@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestDestinationChecker(t *testing.T) {
+	checker, err := newDestinationChecker(DestinationPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for address, allowed := range map[string]bool{
+		"127.0.0.1":              false,
+		"::1":                    false,
+		"::ffff:127.0.0.1":       false,
+		"0.0.0.0":                false,
+		"169.254.169.254":        false,
+		"fe80::1":                false,
+		"fd00:ec2::254":          false,
+		"fc00::1":                false,
+		"::ffff:169.254.169.254": false,
+		"::ffff:0:a9fe:a9fe":     false,
+		"64:ff9b::a9fe:a9fe":     false,
+		"::7f00:1":               false,
+		"64:ff9b::c000:201":      true,
+		"10.0.0.1":               true,
+		"192.0.2.1":              true,
+		"2001:db8::1":            true,
+	} {
+		t.Run(address, func(t *testing.T) {
+			err := checker.check(netip.MustParseAddr(address))
+			if allowed && err != nil {
+				t.Fatalf("address was denied (%v)", err)
+			}
+			if !allowed && err == nil {
+				t.Fatalf("address was allowed")
+			}
+		})
+	}
+}
+
+func TestDestinationCheckerAllowUniqueLocal(t *testing.T) {
+	checker, err := newDestinationChecker(DestinationPolicy{AllowUniqueLocal: true, Deny: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.check(netip.MustParseAddr("fd00::1")); err != nil {
+		t.Fatalf("unique local address was denied (%v)", err)
+	}
+	// The deny list also applies to IPv4 addresses in their mapped and NAT64 forms.
+	for _, address := range []string{"::ffff:10.0.0.1", "64:ff9b::a00:1"} {
+		if err := checker.check(netip.MustParseAddr(address)); err == nil {
+			t.Fatalf("%s was allowed", address)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerssh/log"
+)
+
+// defaultEventStreamRetry is the time to wait before reconnecting to an event stream if the server did not send a
+// retry field.
+const defaultEventStreamRetry = 3 * time.Second
+
+// minEventStreamRetry is the shortest reconnection delay accepted from the server, which prevents a tight reconnect
+// loop when the server sends a very low retry field.
+const minEventStreamRetry = 100 * time.Millisecond
+
+// maxEventLineLength is the longest line accepted in an event stream.
+const maxEventLineLength = 1024 * 1024
+
+// Event is a Server-Sent Event received from the server.
+type Event struct {
+	// ID is the last event ID sent by the server. It is sent in the Last-Event-ID header when reconnecting.
+	ID string
+	// Type is the event type. It is "message" if the server did not send an event field.
+	Type string
+	// Data is the raw data of the event. Multiple data lines are joined with a newline.
+	Data []byte
+	// Value is the decoded data of the event, as returned by the newValue function passed to Subscribe. It is nil if
+	// newValue returned nil for the event type.
+	Value interface{}
+}
+
+// eventStream holds the state of a subscription that is kept between connections.
+type eventStream struct {
+	lastEventID string
+	retry       time.Duration
+}
+
+func (c *client) Subscribe(
+	ctx context.Context,
+	path string,
+	newValue func(eventType string) interface{},
+	onEvent func(event Event),
+	requestOptions ...RequestOption,
+) error {
+	logger := c.logger.WithLabel("method", http.MethodGet).WithLabel("path", path)
+	options := newRequestOptions(requestOptions)
+	if err := options.validate(c.config); err != nil {
+		logger.Debug(err)
+		return err
+	}
+
+	path, err := expandPath(path, options.pathParameters)
+	if err != nil {
+		err := log.Wrap(err, EClientInvalidPath, "Invalid HTTP request path")
+		logger.Debug(err)
+		return err
+	}
+	for _, queryObject := range options.queryObjects {
+		if err := encodeQuery(queryObject, options.query); err != nil {
+			err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request query encoding failed")
+			logger.Critical(err)
+			return err
+		}
+	}
+
+	httpClient := c.createHTTPClient(logger)
+	stream := &eventStream{retry: defaultEventStreamRetry}
+	for {
+		reconnect, err := c.readEventStream(ctx, httpClient, path, options, stream, newValue, onEvent, logger)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !reconnect {
+			return err
+		}
+		logger.Debug(log.Wrap(
+			err,
+			EClientEventStreamDisconnected,
+			"Event stream disconnected, reconnecting in %s",
+			stream.retry,
+		).Label("lastEventID", stream.lastEventID))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(stream.retry):
+		}
+	}
+}
+
+// readEventStream connects to the event stream and reads events until the connection is closed. It returns true if
+// the client should reconnect.
+func (c *client) readEventStream(
+	ctx context.Context,
+	httpClient *http.Client,
+	path string,
+	options *requestOptions,
+	stream *eventStream,
+	newValue func(eventType string) interface{},
+	onEvent func(event Event),
+	logger log.Logger,
+) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := c.createRequest(ctx, c.config.URL, http.MethodGet, path, nil, options, logger)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if stream.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", stream.lastEventID)
+	}
+
+	logger.Debug(log.NewMessage(MClientEventStreamConnect, "Connecting to event stream at %s", req.URL))
+
+	// The timeout only applies until the response headers are received, the stream itself is open-ended.
+	timeout := c.config.Timeout
+	if options.timeout != 0 {
+		timeout = options.timeout
+	}
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := httpClient.Do(req)
+	timer.Stop()
+	if err != nil {
+		var typedError log.Message
+		if errors.As(err, &typedError) {
+			return false, err
+		}
+		return true, log.Wrap(err, EFailureConnectionFailed, "HTTP GET request to %s failed", req.URL)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		// The server asks the client not to reconnect.
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, log.NewMessage(
+			EClientUnexpectedStatus,
+			"Unexpected HTTP status code %d for event stream at %s",
+			resp.StatusCode,
+			req.URL,
+		).Label("statusCode", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		err := log.NewMessage(
+			EClientUnexpectedStatus,
+			"Unexpected HTTP status code %d for event stream at %s",
+			resp.StatusCode,
+			req.URL,
+		).Label("statusCode", resp.StatusCode)
+		logger.Debug(err)
+		return false, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "text/event-stream" {
+		err := log.NewMessage(
+			EClientUnsupportedContentType,
+			"Unsupported content type for event stream: %s",
+			contentType,
+		).Label("contentType", contentType)
+		logger.Debug(err)
+		return false, err
+	}
+
+	err = stream.parse(resp.Body, func(event Event) {
+		if newValue != nil {
+			event.Value = newValue(event.Type)
+		}
+		if event.Value != nil {
+			decoder, _ := c.decoders.find("application/json", nil)
+			if err := decoder.Decode(event.Data, event.Value, !c.allowLaxDecoding); err != nil {
+				logger.Warning(log.Wrap(
+					err,
+					EClientEventDecodeFailed,
+					"Failed to decode %s event",
+					event.Type,
+				).Label("eventType", event.Type).Label("eventID", event.ID))
+				return
+			}
+		}
+		onEvent(event)
+	})
+	if err == nil {
+		err = io.EOF
+	}
+	return true, log.Wrap(err, EClientEventStreamDisconnected, "Event stream at %s closed", req.URL)
+}
+
+// parse reads events from the body and calls dispatch for each complete event, following the Server-Sent Events
+// specification. It returns nil when the body ends.
+func (s *eventStream) parse(body io.Reader, dispatch func(event Event)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 4096), maxEventLineLength)
+	scanner.Split(scanEventLines)
+
+	eventType := ""
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				if eventType == "" {
+					eventType = "message"
+				}
+				dispatch(Event{
+					ID:   s.lastEventID,
+					Type: eventType,
+					Data: bytes.TrimSuffix(data.Bytes(), []byte("\n")),
+				})
+			}
+			eventType = ""
+			data = bytes.Buffer{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+				s.retry = time.Duration(milliseconds) * time.Millisecond
+				if s.retry < minEventStreamRetry {
+					s.retry = minEventStreamRetry
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// scanEventLines splits the event stream into lines ending with CRLF, LF or CR.
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// Wait for the next byte to see if this is a CRLF.
+			return 0, nil, nil
+		}
+	}
+	// An incomplete line at the end of the stream is discarded along with the incomplete event.
+	return 0, nil, nil
+}
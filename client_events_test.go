@@ -0,0 +1,63 @@
+package http
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStreamParse(t *testing.T) {
+	body := ": comment\r\n" +
+		"event: update\r\n" +
+		"id: 1\r\n" +
+		"data: {\"a\":\r\n" +
+		"data:1}\r\n" +
+		"\r\n" +
+		"data: plain\r" +
+		"\r" +
+		"id\n" +
+		"retry: 100\n" +
+		"retry: invalid\n" +
+		"data\n" +
+		"\n" +
+		"event: ignored\n" +
+		"\n" +
+		"data: incomplete\n"
+	stream := &eventStream{retry: defaultEventStreamRetry}
+	var events []Event
+	if err := stream.parse(strings.NewReader(body), func(event Event) {
+		events = append(events, event)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Event{
+		{ID: "1", Type: "update", Data: []byte("{\"a\":\n1}")},
+		{ID: "1", Type: "message", Data: []byte("plain")},
+		{ID: "", Type: "message", Data: []byte("")},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected number of events: %d, expected: %d", len(events), len(expected))
+	}
+	for i, event := range events {
+		if event.ID != expected[i].ID || event.Type != expected[i].Type || string(event.Data) != string(expected[i].Data) {
+			t.Fatalf("unexpected event %d: %v, expected: %v", i, event, expected[i])
+		}
+	}
+	if stream.retry != 100*time.Millisecond {
+		t.Fatalf("unexpected retry interval: %s", stream.retry)
+	}
+}
+
+func TestEventStreamParseMinimumRetry(t *testing.T) {
+	for _, value := range []string{"0", "1", "99"} {
+		t.Run(value, func(t *testing.T) {
+			stream := &eventStream{retry: defaultEventStreamRetry}
+			if err := stream.parse(strings.NewReader("retry: "+value+"\n\n"), func(event Event) {}); err != nil {
+				t.Fatal(err)
+			}
+			if stream.retry != minEventStreamRetry {
+				t.Fatalf("unexpected retry interval: %s, expected: %s", stream.retry, minEventStreamRetry)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package http
 
 import (
 	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
 	"strings"
 
 	"github.com/containerssh/log"
@@ -34,25 +37,108 @@ func NewClientWithHeaders(
 		return nil, err
 	}
 
+	encoder, ok := getRequestEncoder(config.RequestEncoding)
+	if !ok {
+		// This should never happen, the encoding has been validated.
+		panic(fmt.Errorf("BUG: invalid request encoding: %s", config.RequestEncoding))
+	}
+
+	destinationChecker, err := newDestinationChecker(config.DestinationPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	logger = logger.WithLabel("endpoint", config.URL)
+
+	if checker := newRevocationChecker(config.Revocation, logger); checker != nil {
+		checker.apply(tlsConfig)
+	}
+	if reloader := newCertReloader(config, logger); reloader != nil {
+		reloader.apply(tlsConfig)
+	}
+	if tlsConfig != nil {
+		logTLSWarnings(config.tlsWarnings, logger)
+		logNegotiatedTLSVersion(tlsConfig, logger)
+	}
+
+	var jar http.CookieJar
+	if config.CookieJar.Enabled {
+		jar, err = newCookieJar(config.CookieJar, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := createDialer(config, destinationChecker)
+	transport := createTransport(config, tlsConfig, dialer)
+	var roundTripper http.RoundTripper = transport
+	if config.Fixtures.Mode != FixtureModeDisabled {
+		roundTripper, err = newFixtureTransport(config.Fixtures, newDebugLogger(config.Debug), transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var debugLogger *debugLogger
+	if config.Debug.Enabled {
+		debugLogger = newDebugLogger(config.Debug)
+	}
+
 	return &client{
-		config:    config,
-		logger:    logger.WithLabel("endpoint", config.URL),
-		tlsConfig: tlsConfig,
-		extraHeaders: extraHeaders,
-		allowLaxDecoding: allowLaxDecoding,
+		config:             config,
+		jar:                jar,
+		debugLogger:        debugLogger,
+		destinationChecker: destinationChecker,
+		dialer:             dialer,
+		transport:          transport,
+		roundTripper:       roundTripper,
+		encoder:            encoder,
+		decoders:           newResponseDecoderSet(),
+		logger:             logger,
+		tlsConfig:          tlsConfig,
+		extraHeaders:       extraHeaders,
+		allowLaxDecoding:   allowLaxDecoding,
 	}, nil
 }
 
+// createDialer creates the dialer for all connections of a client, which enforces the destination policy.
+func createDialer(config ClientConfiguration, destinationChecker *destinationChecker) *net.Dialer {
+	return &net.Dialer{
+		Timeout: config.DialTimeout,
+		Control: destinationChecker.control,
+	}
+}
+
+// createTransport creates the HTTP transport shared by all requests of a client, so connections can be reused, or in
+// case of HTTP/2, multiplexed.
+func createTransport(
+	config ClientConfiguration,
+	tlsConfig *tls.Config,
+	dialer *net.Dialer,
+) *http.Transport {
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnectionTimeout,
+		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		Protocols:             config.HTTPVersion.getClientProtocols(),
+	}
+}
+
 // createTLSConfig creates a TLS config. Should only be called after config.Validate().
 func createTLSConfig(config ClientConfiguration) (*tls.Config, error) {
 	if !strings.HasPrefix(config.URL, "https://") {
 		return nil, nil
 	}
 
+	settings := config.getTLSSettings()
 	tlsConfig := &tls.Config{
-		MinVersion:       config.TLSVersion.getTLSVersion(),
-		CurvePreferences: config.ECDHCurves.getList(),
-		CipherSuites:     config.CipherSuites.getList(),
+		MinVersion:       settings.minVersion.getTLSVersion(),
+		MaxVersion:       settings.maxVersion.getMaxTLSVersion(),
+		CurvePreferences: settings.curves.getList(),
+		CipherSuites:     settings.cipherSuites.getList(),
 	}
 	if config.caCertPool != nil {
 		tlsConfig.RootCAs = config.caCertPool
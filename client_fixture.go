@@ -0,0 +1,353 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/containerssh/log"
+)
+
+// fixture is a recorded request and response pair in the fixture file.
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+// fixtureRequest is the recorded request. Only the headers used for matching are recorded so credentials do not end
+// up in the fixture file. Headers redacted in debug logs are recorded with a placeholder value. The body is only
+// recorded when matching on the body, with the fields redacted in debug logs replaced by a placeholder value. Bodies
+// that cannot be redacted are recorded as their SHA-256 digest.
+type fixtureRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    fixtureBody         `json:"body,omitempty"`
+}
+
+// fixtureResponse is the recorded response. The values of headers redacted in debug logs, such as Set-Cookie, are
+// replaced with a placeholder and are left out of replayed responses.
+type fixtureResponse struct {
+	StatusCode int                 `json:"statusCode"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       fixtureBody         `json:"body,omitempty"`
+}
+
+// fixtureBody is a recorded body. Bodies that are valid UTF-8 are stored as a string to keep fixture files readable.
+// Other bodies, such as MessagePack or CBOR, are stored as an object with the base64-encoded body, since JSON strings
+// cannot hold arbitrary bytes.
+type fixtureBody []byte
+
+// fixtureBinaryBody is the format of a body that is not valid UTF-8 in the fixture file.
+type fixtureBinaryBody struct {
+	Base64 []byte `json:"base64"`
+}
+
+func (b fixtureBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(fixtureBinaryBody{Base64: b})
+}
+
+func (b *fixtureBody) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		binary := fixtureBinaryBody{}
+		if err := json.Unmarshal(data, &binary); err != nil {
+			return err
+		}
+		*b = binary.Base64
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = fixtureBody(text)
+	return nil
+}
+
+// fixtureTransport records requests and responses to a fixture file, or serves responses from the fixture file
+// without sending the request to the server.
+type fixtureTransport struct {
+	next    http.RoundTripper
+	mode    FixtureMode
+	file    string
+	match   map[FixtureMatchField]bool
+	headers []string
+	// redactor applies the debug log redaction rules to the headers and bodies written to the fixture file.
+	redactor *debugLogger
+	lock     *sync.Mutex
+	fixtures []fixture
+	// used counts how many times each fixture has been served in replay mode.
+	used []int
+}
+
+// newFixtureTransport creates the fixture transport for the configured mode. In replay mode the fixture file is
+// loaded immediately.
+func newFixtureTransport(
+	config FixtureConfiguration,
+	redactor *debugLogger,
+	next http.RoundTripper,
+) (*fixtureTransport, error) {
+	matchOn := config.MatchOn
+	if len(matchOn) == 0 {
+		matchOn = []FixtureMatchField{FixtureMatchMethod, FixtureMatchPath}
+	}
+	match := make(map[FixtureMatchField]bool, len(matchOn))
+	for _, field := range matchOn {
+		match[field] = true
+	}
+	headers := make([]string, len(config.MatchHeaders))
+	for i, header := range config.MatchHeaders {
+		headers[i] = http.CanonicalHeaderKey(header)
+	}
+	result := &fixtureTransport{
+		next:     next,
+		mode:     config.Mode,
+		file:     config.File,
+		match:    match,
+		headers:  headers,
+		redactor: redactor,
+		lock:     &sync.Mutex{},
+	}
+	if config.Mode == FixtureModeReplay {
+		data, err := ioutil.ReadFile(config.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture file %s (%w)", config.File, err)
+		}
+		if err := json.Unmarshal(data, &result.fixtures); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture file %s (%w)", config.File, err)
+		}
+		result.used = make([]int, len(result.fixtures))
+	}
+	return result, nil
+}
+
+func (f *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	request := fixtureRequest{
+		Method:  req.Method,
+		Path:    req.URL.RequestURI(),
+		Headers: f.matchedHeaders(req.Header),
+		Body:    f.requestBody(req.Header.Get("Content-Type"), body),
+	}
+	if f.mode == FixtureModeReplay {
+		return f.replay(req, request)
+	}
+	return f.record(req, request)
+}
+
+// requestBody returns the request body as it is recorded and matched. Bodies are only needed for matching, so they
+// are left out unless matching on the body is enabled.
+func (f *fixtureTransport) requestBody(contentType string, body []byte) fixtureBody {
+	if !f.match[FixtureMatchBody] || len(body) == 0 {
+		return nil
+	}
+	if redacted, err := f.redactor.redactFields(bodyMediaType(contentType), body); err == nil {
+		return redacted
+	}
+	digest := sha256.Sum256(body)
+	return fixtureBody("sha256:" + hex.EncodeToString(digest[:]))
+}
+
+func (f *fixtureTransport) matchedHeaders(header http.Header) map[string][]string {
+	if len(f.headers) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(f.headers))
+	for _, name := range f.headers {
+		if values := header.Values(name); len(values) > 0 {
+			result[name] = values
+		}
+	}
+	return result
+}
+
+// matches returns true if the recorded request matches the request on all configured fields.
+func (f *fixtureTransport) matches(recorded fixtureRequest, request fixtureRequest) bool {
+	if f.match[FixtureMatchMethod] && recorded.Method != request.Method {
+		return false
+	}
+	if f.match[FixtureMatchPath] && recorded.Path != request.Path {
+		return false
+	}
+	if f.match[FixtureMatchBody] && !bytes.Equal(recorded.Body, request.Body) {
+		return false
+	}
+	for _, name := range f.headers {
+		recordedValues := http.Header(recorded.Headers).Values(name)
+		if f.isRedacted(name, recordedValues) {
+			// The value was not recorded, so only the presence of the header can be matched.
+			if len(request.Headers[name]) == 0 {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(recordedValues) != fmt.Sprint(request.Headers[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRedacted returns true if the recorded header values are the placeholder written for a redacted header.
+func (f *fixtureTransport) isRedacted(name string, values []string) bool {
+	return f.redactor.headers[http.CanonicalHeaderKey(name)] && len(values) == 1 && values[0] == redactedValue
+}
+
+// replayedHeaders returns the recorded response headers without the redacted ones, whose values are unknown.
+func (f *fixtureTransport) replayedHeaders(recorded map[string][]string) http.Header {
+	header := make(http.Header, len(recorded))
+	for name, values := range recorded {
+		if !f.isRedacted(name, values) {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	return header
+}
+
+// replay serves the response of the first matching fixture that has not been used yet. If all matching fixtures have
+// been used, the last one is served again.
+func (f *fixtureTransport) replay(req *http.Request, request fixtureRequest) (*http.Response, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	found := -1
+	for i, recorded := range f.fixtures {
+		if !f.matches(recorded.Request, request) {
+			continue
+		}
+		found = i
+		if f.used[i] == 0 {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, log.NewMessage(
+			EClientFixtureNotFound,
+			"No fixture matches the HTTP %s request to %s",
+			req.Method,
+			req.URL,
+		).Label("method", req.Method).Label("path", request.Path)
+	}
+	f.used[found]++
+	response := f.fixtures[found].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.replayedHeaders(response.Headers),
+		Body:          ioutil.NopCloser(bytes.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// record sends the request to the server and appends the request and response to the fixture file. Event streams
+// are passed to the caller as they arrive and recorded when the caller closes the body, other responses are read
+// completely before they are returned.
+func (f *fixtureTransport) record(req *http.Request, request fixtureRequest) (*http.Response, error) {
+	if request.Headers != nil {
+		request.Headers = redactHeaders(request.Headers, f.redactor.headers)
+	}
+	resp, err := f.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := fixture{
+		Request: request,
+		Response: fixtureResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header, f.redactor.headers),
+		},
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		resp.Body = &fixtureRecordingBody{
+			ReadCloser: resp.Body,
+			buffer:     &bytes.Buffer{},
+			onClose: func(body []byte) error {
+				recorded.Response.Body = body
+				return f.add(recorded)
+			},
+		}
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	recorded.Response.Body = body
+	if err := f.add(recorded); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// add appends the fixture and saves the fixture file.
+func (f *fixtureTransport) add(recorded fixture) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.fixtures = append(f.fixtures, recorded)
+	if err := f.save(); err != nil {
+		return log.Wrap(err, EClientFixtureSaveFailed, "Failed to save fixture file %s", f.file)
+	}
+	return nil
+}
+
+// fixtureRecordingBody passes a streamed response body to the caller and records the part that has been read when
+// the body is closed.
+type fixtureRecordingBody struct {
+	io.ReadCloser
+	buffer  *bytes.Buffer
+	onClose func(body []byte) error
+	closed  bool
+}
+
+func (b *fixtureRecordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buffer.Write(p[:n])
+	return n, err
+}
+
+func (b *fixtureRecordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.closed {
+		return err
+	}
+	b.closed = true
+	if recordErr := b.onClose(b.buffer.Bytes()); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// save writes the fixtures to the file.
+func (f *fixtureTransport) save() error {
+	data, err := json.MarshalIndent(f.fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.file, data)
+}
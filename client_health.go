@@ -0,0 +1,246 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/containerssh/log"
+)
+
+// HealthStage is a stage of the client health check.
+type HealthStage string
+
+const (
+	// HealthStageDNS resolves the host name of the URL.
+	HealthStageDNS HealthStage = "dns"
+	// HealthStageTCP opens a TCP connection to the resolved addresses.
+	HealthStageTCP HealthStage = "tcp"
+	// HealthStageTLS performs a TLS handshake on the TCP connection. It is skipped for http:// URLs.
+	HealthStageTLS HealthStage = "tls"
+	// HealthStageHTTP sends the configured probe request.
+	HealthStageHTTP HealthStage = "http"
+)
+
+// HealthStatus is the outcome of a health check stage.
+type HealthStatus string
+
+const (
+	// HealthStatusPassed indicates that the stage succeeded.
+	HealthStatusPassed HealthStatus = "passed"
+	// HealthStatusFailed indicates that the stage failed.
+	HealthStatusFailed HealthStatus = "failed"
+	// HealthStatusSkipped indicates that the stage was not run because it does not apply or an earlier stage failed.
+	HealthStatusSkipped HealthStatus = "skipped"
+)
+
+// HealthStageResult is the result of a single health check stage.
+type HealthStageResult struct {
+	// Stage is the stage this result belongs to.
+	Stage HealthStage `json:"stage"`
+	// Status is the outcome of the stage.
+	Status HealthStatus `json:"status"`
+	// Duration is the time the stage took.
+	Duration time.Duration `json:"duration"`
+	// Detail describes what the stage found, for example the resolved addresses or the negotiated TLS version.
+	Detail string `json:"detail,omitempty"`
+	// Error is the reason the stage failed.
+	Error error `json:"-"`
+}
+
+// HealthReport is the result of a client health check. It contains the results of all stages in order.
+type HealthReport struct {
+	// URL is the URL that was checked.
+	URL string `json:"url"`
+	// Stages contains the result of each stage.
+	Stages []HealthStageResult `json:"stages"`
+}
+
+// Healthy returns true if no stage failed.
+func (r HealthReport) Healthy() bool {
+	return r.FailedStage() == nil
+}
+
+// FailedStage returns the result of the stage that failed, or nil if the health check passed.
+func (r HealthReport) FailedStage() *HealthStageResult {
+	for i := range r.Stages {
+		if r.Stages[i].Status == HealthStatusFailed {
+			return &r.Stages[i]
+		}
+	}
+	return nil
+}
+
+// Err returns an error describing the failed stage, or nil if the health check passed.
+func (r HealthReport) Err() error {
+	failed := r.FailedStage()
+	if failed == nil {
+		return nil
+	}
+	return log.Wrap(
+		failed.Error,
+		EClientHealthCheckFailed,
+		"Health check of %s failed in the %s stage",
+		r.URL,
+		failed.Stage,
+	).Label("stage", failed.Stage)
+}
+
+func (c *client) HealthCheck(ctx context.Context) HealthReport {
+	report := HealthReport{URL: c.config.URL}
+	stages := []struct {
+		stage HealthStage
+		run   func(ctx context.Context, state *healthCheckState) (string, error)
+	}{
+		{HealthStageDNS, c.healthCheckDNS},
+		{HealthStageTCP, c.healthCheckTCP},
+		{HealthStageTLS, c.healthCheckTLS},
+		{HealthStageHTTP, c.healthCheckHTTP},
+	}
+	state := &healthCheckState{}
+	defer state.close()
+	failed := false
+	for _, stage := range stages {
+		if failed {
+			report.Stages = append(report.Stages, HealthStageResult{Stage: stage.stage, Status: HealthStatusSkipped})
+			continue
+		}
+		stageCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		start := time.Now()
+		detail, err := stage.run(stageCtx, state)
+		cancel()
+		result := HealthStageResult{
+			Stage:    stage.stage,
+			Status:   HealthStatusPassed,
+			Duration: time.Since(start),
+			Detail:   detail,
+		}
+		if err == errHealthStageSkipped {
+			result.Status = HealthStatusSkipped
+		} else if err != nil {
+			result.Status = HealthStatusFailed
+			result.Error = err
+			failed = true
+		}
+		report.Stages = append(report.Stages, result)
+	}
+
+	if err := report.Err(); err != nil {
+		c.logger.Debug(err)
+	} else {
+		c.logger.Debug(log.NewMessage(MClientHealthCheckPassed, "Health check of %s passed", c.config.URL))
+	}
+	return report
+}
+
+// errHealthStageSkipped is returned by a stage that does not apply to the configured URL.
+var errHealthStageSkipped = fmt.Errorf("stage skipped")
+
+// healthCheckState carries the results of a stage to the next one.
+type healthCheckState struct {
+	host  string
+	port  string
+	addrs []netip.Addr
+	conn  net.Conn
+}
+
+func (s *healthCheckState) close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+}
+
+func (c *client) healthCheckDNS(ctx context.Context, state *healthCheckState) (string, error) {
+	u, err := url.Parse(c.config.URL)
+	if err != nil {
+		return "", err
+	}
+	state.host = u.Hostname()
+	state.port = u.Port()
+	if state.port == "" {
+		state.port = "80"
+		if u.Scheme == "https" {
+			state.port = "443"
+		}
+	}
+	if addr, err := netip.ParseAddr(state.host); err == nil {
+		state.addrs = []netip.Addr{addr}
+		return fmt.Sprintf("%s is an IP address", state.host), nil
+	}
+	state.addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", state.host)
+	if err != nil {
+		return "", err
+	}
+	resolved := make([]string, len(state.addrs))
+	for i, addr := range state.addrs {
+		resolved[i] = addr.String()
+	}
+	return fmt.Sprintf("%s resolves to %s", state.host, strings.Join(resolved, ", ")), nil
+}
+
+// healthCheckTCP connects to the resolved addresses in turn until one succeeds. The dialer of the client enforces the
+// destination policy.
+func (c *client) healthCheckTCP(ctx context.Context, state *healthCheckState) (string, error) {
+	var lastErr error
+	for _, addr := range state.addrs {
+		address := net.JoinHostPort(addr.String(), state.port)
+		conn, err := c.dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		state.conn = conn
+		return fmt.Sprintf("connected to %s", address), nil
+	}
+	return "", lastErr
+}
+
+func (c *client) healthCheckTLS(ctx context.Context, state *healthCheckState) (string, error) {
+	if c.tlsConfig == nil {
+		return "", errHealthStageSkipped
+	}
+	tlsConfig := c.tlsConfig.Clone()
+	tlsConfig.ServerName = state.host
+	tlsConn := tls.Client(state.conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+	state.conn = tlsConn
+	connectionState := tlsConn.ConnectionState()
+	return fmt.Sprintf(
+		"negotiated %s with %s using %s",
+		tls.VersionName(connectionState.Version),
+		tls.CipherSuiteName(connectionState.CipherSuite),
+		negotiatedKeyExchange(connectionState),
+	), nil
+}
+
+// healthCheckHTTP sends the probe request using the regular client, so it uses the same transport, headers and
+// redirect policy as any other request.
+func (c *client) healthCheckHTTP(ctx context.Context, _ *healthCheckState) (string, error) {
+	method := c.config.HealthCheck.getMethod()
+	logger := c.logger.WithLabel("method", method).WithLabel("path", c.config.HealthCheck.Path)
+	result := c.do(
+		ctx,
+		c.createHTTPClient(logger),
+		c.config.URL,
+		method,
+		c.config.HealthCheck.Path,
+		nil,
+		newRequestOptions(nil),
+		logger,
+	)
+	if result.err != nil {
+		return "", result.err
+	}
+	detail := fmt.Sprintf("%s %s returned status %d", method, c.config.HealthCheck.Path, result.statusCode)
+	if !c.config.HealthCheck.isExpectedStatus(result.statusCode) {
+		return detail, fmt.Errorf("unexpected status code %d", result.statusCode)
+	}
+	return detail, nil
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/containerssh/log"
+)
+
+// isIdempotent returns true if the HTTP method can safely be sent more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet:
+	case http.MethodHead:
+	case http.MethodOptions:
+	case http.MethodPut:
+	case http.MethodDelete:
+	case http.MethodTrace:
+	default:
+		return false
+	}
+	return true
+}
+
+// isGood returns true if the result can be returned to the caller without waiting for a hedged request.
+func (r clientResult) isGood() bool {
+	return r.err == nil && r.statusCode < 500
+}
+
+// hedgedResult is a clientResult tagged with the attempt it belongs to.
+type hedgedResult struct {
+	attempt int
+	result  clientResult
+}
+
+// hedgedRequest sends the request to the main URL and, if no good response arrives within the hedging delay, sends
+// a second request to the next hedging URL. The first good response is returned and the other request is canceled.
+// If both requests fail the result of the original request is returned.
+func (c *client) hedgedRequest(
+	parentCtx context.Context,
+	httpClient *http.Client,
+	method string,
+	path string,
+	body *encodedBody,
+	options *requestOptions,
+	logger log.Logger,
+) clientResult {
+	ctx, cancel := context.WithCancel(parentCtx)
+	// Canceling the context aborts whichever request is still running when we return.
+	defer cancel()
+
+	results := make(chan hedgedResult, 2)
+	send := func(attempt int, baseURL string) {
+		go func() {
+			results <- hedgedResult{
+				attempt: attempt,
+				result:  c.do(ctx, httpClient, baseURL, method, path, body, options, logger),
+			}
+		}()
+	}
+	send(0, c.config.URL)
+
+	timer := time.NewTimer(c.config.Hedging.Delay)
+	defer timer.Stop()
+	hedge := func() {
+		baseURL := c.nextHedgeURL()
+		logger.Debug(log.NewMessage(
+			MClientHedgedRequest,
+			"Sending hedged HTTP %s request to %s%s",
+			method,
+			baseURL,
+			path,
+		).Label("hedgeURL", baseURL))
+		send(1, baseURL)
+	}
+
+	hedged := false
+	pending := 1
+	var original clientResult
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				hedge()
+			}
+		case r := <-results:
+			pending--
+			if r.result.isGood() {
+				return r.result
+			}
+			if r.attempt == 0 {
+				original = r.result
+			}
+			if !hedged {
+				// The original request failed before the delay expired, send the hedged request right away.
+				hedged = true
+				pending++
+				hedge()
+			} else if pending == 0 {
+				return original
+			}
+		}
+	}
+}
+
+// nextHedgeURL returns the base URL to send the next hedged request to.
+func (c *client) nextHedgeURL() string {
+	if len(c.config.Hedging.URLs) == 0 {
+		return c.config.URL
+	}
+	n := atomic.AddUint32(&c.hedgeCounter, 1) - 1
+	return c.config.Hedging.URLs[n%uint32(len(c.config.Hedging.URLs))]
+}
@@ -2,36 +2,47 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"net/url"
 
 	"github.com/containerssh/log"
-	"github.com/gorilla/schema"
 )
 
 type client struct {
-	config           ClientConfiguration
-	logger           log.Logger
-	tlsConfig        *tls.Config
-	extraHeaders     map[string][]string
-	allowLaxDecoding bool
+	config             ClientConfiguration
+	logger             log.Logger
+	tlsConfig          *tls.Config
+	dialer             *net.Dialer
+	transport          *http.Transport
+	roundTripper       http.RoundTripper
+	destinationChecker *destinationChecker
+	jar                http.CookieJar
+	debugLogger        *debugLogger
+	encoder            RequestEncoder
+	decoders           *responseDecoderSet
+	extraHeaders       map[string][]string
+	allowLaxDecoding   bool
+	// hedgeCounter is used to rotate between the configured hedging URLs.
+	hedgeCounter uint32
 }
 
 func (c *client) Put(
 	path string,
 	requestBody interface{},
 	responseBody interface{},
+	options ...RequestOption,
 ) (statusCode int, err error) {
 	return c.request(
 		http.MethodPut,
 		path,
 		requestBody,
 		responseBody,
+		options,
 	)
 }
 
@@ -39,12 +50,14 @@ func (c *client) Patch(
 	path string,
 	requestBody interface{},
 	responseBody interface{},
+	options ...RequestOption,
 ) (statusCode int, err error) {
 	return c.request(
 		http.MethodPatch,
 		path,
 		requestBody,
 		responseBody,
+		options,
 	)
 }
 
@@ -52,30 +65,50 @@ func (c *client) Delete(
 	path string,
 	requestBody interface{},
 	responseBody interface{},
+	options ...RequestOption,
 ) (statusCode int, err error) {
 	return c.request(
 		http.MethodDelete,
 		path,
 		requestBody,
 		responseBody,
+		options,
 	)
 }
 
-func (c *client) Request(Method string, path string, requestBody interface{}, responseBody interface{}) (statusCode int, err error) {
+func (c *client) Request(
+	Method string,
+	path string,
+	requestBody interface{},
+	responseBody interface{},
+	options ...RequestOption,
+) (statusCode int, err error) {
 	return c.request(
 		Method,
 		path,
 		requestBody,
 		responseBody,
+		options,
 	)
 }
 
-func (c *client) Get(path string, responseBody interface{}) (statusCode int, err error) {
+func (c *client) Get(path string, responseBody interface{}, options ...RequestOption) (statusCode int, err error) {
 	return c.request(
 		http.MethodGet,
 		path,
 		nil,
 		responseBody,
+		options,
+	)
+}
+
+func (c *client) Head(path string, options ...RequestOption) (statusCode int, err error) {
+	return c.request(
+		http.MethodHead,
+		path,
+		nil,
+		nil,
+		options,
 	)
 }
 
@@ -83,6 +116,7 @@ func (c *client) Post(
 	path string,
 	requestBody interface{},
 	responseBody interface{},
+	options ...RequestOption,
 ) (
 	int,
 	error,
@@ -92,6 +126,7 @@ func (c *client) Post(
 		path,
 		requestBody,
 		responseBody,
+		options,
 	)
 }
 
@@ -100,27 +135,141 @@ func (c *client) request(
 	path string,
 	requestBody interface{},
 	responseBody interface{},
+	requestOptions []RequestOption,
 ) (int, error) {
 	logger := c.logger.WithLabel("method", method).WithLabel("path", path)
+	options := newRequestOptions(requestOptions)
+	if err := options.validate(c.config); err != nil {
+		logger.Debug(err)
+		return 0, err
+	}
+
+	path, err := expandPath(path, options.pathParameters)
+	if err != nil {
+		err := log.Wrap(err, EClientInvalidPath, "Invalid HTTP request path")
+		logger.Debug(err)
+		return 0, err
+	}
 
 	httpClient := c.createHTTPClient(logger)
 
-	req, err := c.createRequest(method, path, requestBody, logger)
+	queryObjects := options.queryObjects
+	var body *encodedBody
+	if hasNoBody(method) && isQueryObject(requestBody) {
+		queryObjects = append(queryObjects, requestBody)
+	} else {
+		body, err = c.encodeRequestBody(requestBody, logger)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, queryObject := range queryObjects {
+		if err := encodeQuery(queryObject, options.query); err != nil {
+			err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request query encoding failed")
+			logger.Critical(err)
+			return 0, err
+		}
+	}
+
+	timeout := c.config.Timeout
+	if options.timeout != 0 {
+		timeout = options.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var result clientResult
+	if c.config.Hedging.Enabled && isIdempotent(method) {
+		result = c.hedgedRequest(ctx, httpClient, method, path, body, options, logger)
+	} else {
+		result = c.do(ctx, httpClient, c.config.URL, method, path, body, options, logger)
+	}
+	if result.err != nil {
+		return result.statusCode, result.err
+	}
+
+	if !options.isExpectedStatus(result.statusCode) {
+		err := log.NewMessage(
+			EClientUnexpectedStatus,
+			"Unexpected HTTP status code %d for %s request to %s%s",
+			result.statusCode,
+			method,
+			c.config.URL,
+			path,
+		).Label("statusCode", result.statusCode)
+		logger.Debug(err)
+		return result.statusCode, err
+	}
+
+	if responseBody == nil {
+		return result.statusCode, nil
+	}
+	decoder, ok := c.decoders.find(result.contentType, result.body)
+	if !ok {
+		err := log.NewMessage(
+			EClientUnsupportedContentType,
+			"Unsupported content type in HTTP response: %s",
+			result.contentType,
+		).Label("contentType", result.contentType)
+		logger.Debug(err)
+		return result.statusCode, err
+	}
+	if err := decoder.Decode(result.body, responseBody, !c.allowLaxDecoding); err != nil {
+		err = log.Wrap(err, EFailureDecodeFailed, "Failed to decode HTTP response")
+		logger.Debug(err)
+		return result.statusCode, err
+	}
+	return result.statusCode, nil
+}
+
+// clientResult is the outcome of a single HTTP request, including the fully read response body.
+type clientResult struct {
+	statusCode  int
+	contentType string
+	body        []byte
+	err         error
+}
+
+// encodedBody is a request body encoded with the request encoder, together with its content type.
+type encodedBody struct {
+	data        []byte
+	contentType string
+}
+
+// do sends a single HTTP request to the specified base URL and reads the response.
+func (c *client) do(
+	ctx context.Context,
+	httpClient *http.Client,
+	baseURL string,
+	method string,
+	path string,
+	body *encodedBody,
+	options *requestOptions,
+	logger log.Logger,
+) clientResult {
+	req, err := c.createRequest(ctx, baseURL, method, path, body, options, logger)
 	if err != nil {
-		return 0, err
+		return clientResult{err: err}
 	}
 
-	logger.Debug(log.NewMessage(MClientRequest, "HTTP %s request to %s%s", method, c.config.URL, path))
+	logger.Debug(log.NewMessage(MClientRequest, "HTTP %s request to %s", method, req.URL))
+	if c.debugLogger != nil {
+		var data []byte
+		if body != nil {
+			data = body.data
+		}
+		c.debugLogger.logRequest(logger, req, data)
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		var typedError log.Message
 		if errors.As(err, &typedError) {
-			return 0, err
+			return clientResult{err: err}
 		}
-		err = log.Wrap(err, EFailureConnectionFailed, "HTTP %s request to %s%s failed", method, c.config.URL, path)
+		err = log.Wrap(err, EFailureConnectionFailed, "HTTP %s request to %s failed", method, req.URL)
 		logger.Debug(err)
-		return 0, err
+		return clientResult{err: err}
 	}
 	defer func() { _ = resp.Body.Close() }()
 
@@ -130,60 +279,60 @@ func (c *client) request(
 		resp.StatusCode,
 	).Label("statusCode", resp.StatusCode))
 
-	body, err := ioutil.ReadAll(resp.Body)
+	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		err = log.Wrap(err, EFailureConnectionFailed, "HTTP %s request to %s%s failed", method, c.config.URL, path)
+		err = log.Wrap(err, EFailureConnectionFailed, "HTTP %s request to %s failed", method, req.URL)
 		logger.Debug(err)
-		return 0, err
+		return clientResult{err: err}
 	}
-
-	if responseBody == nil {
-		return resp.StatusCode, nil
+	if c.debugLogger != nil {
+		c.debugLogger.logResponse(logger, resp, responseBody)
 	}
-	decoder := json.NewDecoder(bytes.NewReader(body))
-	if !c.allowLaxDecoding {
-		decoder.DisallowUnknownFields()
+	return clientResult{
+		statusCode:  resp.StatusCode,
+		contentType: resp.Header.Get("Content-Type"),
+		body:        responseBody,
 	}
-	if err := decoder.Decode(responseBody); err != nil {
-		err = log.Wrap(err, EFailureDecodeFailed, "Failed to decode HTTP response")
-		logger.Debug(err)
-		return resp.StatusCode, err
+}
+
+func (c *client) encodeRequestBody(requestBody interface{}, logger log.Logger) (*encodedBody, error) {
+	data, contentType, err := c.encoder.Encode(requestBody)
+	if err != nil {
+		//This is a bug
+		err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request encoding failed")
+		logger.Critical(err)
+		return nil, err
 	}
-	return resp.StatusCode, nil
+	return &encodedBody{data: data, contentType: contentType}, nil
 }
 
-func (c *client) createRequest(method string, path string, requestBody interface{}, logger log.Logger) (
+func (c *client) createRequest(
+	ctx context.Context,
+	baseURL string,
+	method string,
+	path string,
+	body *encodedBody,
+	options *requestOptions,
+	logger log.Logger,
+) (
 	*http.Request,
 	error,
 ) {
-	buffer := &bytes.Buffer{}
-	switch c.config.RequestEncoding {
-	case RequestEncodingDefault:
-		fallthrough
-	case RequestEncodingJSON:
-		err := json.NewEncoder(buffer).Encode(requestBody)
-		if err != nil {
-			//This is a bug
-			err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request encoding failed")
-			logger.Critical(err)
-			return nil, err
-		}
-	case RequestEncodingWWWURLEncoded:
-		encoder := schema.NewEncoder()
-		form := url.Values{}
-		if err := encoder.Encode(requestBody, form); err != nil {
-			err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request encoding failed")
-			logger.Critical(err)
-			return nil, err
-		}
-		buffer.WriteString(form.Encode())
-	default:
-		panic(fmt.Errorf("invalid request encoding: %s", c.config.RequestEncoding))
+	requestURL, err := joinURL(baseURL, path)
+	if err != nil {
+		err := log.Wrap(err, EClientInvalidPath, "Invalid HTTP request path %s", path)
+		logger.Debug(err)
+		return nil, err
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body.data)
 	}
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		method,
-		fmt.Sprintf("%s%s", c.config.URL, path),
-		buffer,
+		requestURL.String(),
+		bodyReader,
 	)
 	if err != nil {
 		err := log.Wrap(err, EFailureEncodeFailed, "BUG: HTTP request encoding failed")
@@ -199,42 +348,24 @@ func (c *client) createRequest(method string, path string, requestBody interface
 			}
 		}
 	}
-	switch c.config.RequestEncoding {
-	case RequestEncodingDefault:
-		fallthrough
-	case RequestEncodingJSON:
-		req.Header.Set("Content-Type", "application/json")
-	case RequestEncodingWWWURLEncoded:
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	default:
-		panic(fmt.Errorf("invalid request encoding: %s", c.config.RequestEncoding))
-	}
-	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	req.Header.Set("Accept", c.decoders.accept)
+	options.applyHeaders(req.Header)
+	if len(options.query) > 0 {
+		query := req.URL.Query()
+		addQuery(query, options.query)
+		req.URL.RawQuery = query.Encode()
+	}
 	return req, nil
 }
 
 func (c *client) createHTTPClient(logger log.Logger) *http.Client {
-	transport := &http.Transport{
-		TLSClientConfig: c.tlsConfig,
-	}
-
 	httpClient := &http.Client{
-		Transport: transport,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if !c.config.AllowRedirects {
-				return log.NewMessage(
-					EClientRedirectsDisabled,
-					"Redirects disabled, server tried to redirect to %s", req.URL,
-				).Label("redirect", req.URL)
-			}
-			logger.Debug(
-				log.NewMessage(
-					MClientRedirect, "HTTP redirect to %s", req.URL,
-				).Label("redirect", req.URL),
-			)
-			return nil
-		},
-		Timeout: c.config.Timeout,
+		Transport:     c.roundTripper,
+		CheckRedirect: c.checkRedirect(logger),
+		Jar:           c.jar,
 	}
 	return httpClient
 }
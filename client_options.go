@@ -0,0 +1,157 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/containerssh/log"
+)
+
+// RequestOption is an option that changes the behavior of a single client request. Options are passed as the last
+// parameters of the Client methods.
+type RequestOption func(options *requestOptions)
+
+// requestOptions is the collected set of options for a single request.
+type requestOptions struct {
+	// setHeaders are headers that replace the headers set by the client.
+	setHeaders http.Header
+	// addHeaders are headers that are added in addition to the headers set by the client.
+	addHeaders http.Header
+	// pathParameters are the values for the placeholders in the path template.
+	pathParameters map[string]string
+	// query are the query parameters to add to the request URL.
+	query url.Values
+	// queryObjects are objects to encode into the query string.
+	queryObjects []interface{}
+	// timeout overrides the client timeout if not zero.
+	timeout time.Duration
+	// expectedStatus is the list of acceptable status codes. If empty, all status codes are accepted.
+	expectedStatus []int
+}
+
+func newRequestOptions(options []RequestOption) *requestOptions {
+	result := &requestOptions{
+		setHeaders:     http.Header{},
+		addHeaders:     http.Header{},
+		pathParameters: map[string]string{},
+		query:          url.Values{},
+	}
+	for _, option := range options {
+		option(result)
+	}
+	return result
+}
+
+// validate checks the options against the client configuration. A timeout set with WithTimeout must satisfy the same
+// rules as the Timeout of the configuration, since it replaces it for the request.
+func (o *requestOptions) validate(config ClientConfiguration) error {
+	if o.timeout == 0 {
+		return nil
+	}
+	if err := config.validateRequestTimeout(o.timeout); err != nil {
+		return log.Wrap(err, EClientInvalidTimeout, "Invalid per-request timeout")
+	}
+	return nil
+}
+
+// isExpectedStatus returns true if the status code is acceptable for this request.
+func (o *requestOptions) isExpectedStatus(statusCode int) bool {
+	if len(o.expectedStatus) == 0 {
+		return true
+	}
+	for _, expected := range o.expectedStatus {
+		if expected == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHeaders sets and adds the per-request headers on the request. It must be called after the client headers
+// have been set.
+func (o *requestOptions) applyHeaders(header http.Header) {
+	for name, values := range o.setHeaders {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	for name, values := range o.addHeaders {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+}
+
+// WithHeader sets a header for a single request, replacing any value the client would send for the same header,
+// including the extra headers passed to NewClientWithHeaders. Passing the same header more than once sends all
+// values.
+func WithHeader(name string, value string) RequestOption {
+	return func(options *requestOptions) {
+		options.setHeaders.Add(name, value)
+	}
+}
+
+// WithExtraHeader adds a header value to a single request in addition to the headers the client sends.
+func WithExtraHeader(name string, value string) RequestOption {
+	return func(options *requestOptions) {
+		options.addHeaders.Add(name, value)
+	}
+}
+
+// WithPathParameter sets the value for the {name} placeholder in the request path, for example /users/{user}/keys.
+// The value is escaped as a single path segment, so it can safely contain characters like / or ?.
+func WithPathParameter(name string, value string) RequestOption {
+	return func(options *requestOptions) {
+		options.pathParameters[name] = value
+	}
+}
+
+// WithPathParameters sets the values for multiple placeholders in the request path. See WithPathParameter.
+func WithPathParameters(parameters map[string]string) RequestOption {
+	return func(options *requestOptions) {
+		for name, value := range parameters {
+			options.pathParameters[name] = value
+		}
+	}
+}
+
+// WithQuery adds the query values to the request URL. Values already present in the path are kept.
+func WithQuery(values url.Values) RequestOption {
+	return func(options *requestOptions) {
+		addQuery(options.query, values)
+	}
+}
+
+// WithQueryObject encodes the object into the query string of the request URL. The object can be a struct with schema
+// tags, url.Values, or a map of strings. This is useful for sending parameters with Get, which has no request body.
+func WithQueryObject(object interface{}) RequestOption {
+	return func(options *requestOptions) {
+		options.queryObjects = append(options.queryObjects, object)
+	}
+}
+
+// WithQueryParameter adds a single query parameter to the request URL.
+func WithQueryParameter(name string, value string) RequestOption {
+	return func(options *requestOptions) {
+		options.query.Add(name, value)
+	}
+}
+
+// WithTimeout overrides the timeout from the ClientConfiguration for a single request. The timeout must be at least
+// 100ms and not lower than the configured timeouts of the individual phases, such as DialTimeout, otherwise the
+// request fails with EClientInvalidTimeout.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(options *requestOptions) {
+		options.timeout = timeout
+	}
+}
+
+// WithExpectedStatus sets the list of status codes that are acceptable for a single request. If the server responds
+// with a different status code the request fails with EClientUnexpectedStatus and the response body is not decoded.
+func WithExpectedStatus(statusCodes ...int) RequestOption {
+	return func(options *requestOptions) {
+		options.expectedStatus = append(options.expectedStatus, statusCodes...)
+	}
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// expandPath replaces the {name} placeholders in the path template with the escaped path parameters. Each value is
+// escaped as a single path segment, so values containing / or ? cannot change the path structure. The values . and
+// .. are rejected since they would traverse the path. Placeholders after the ? are escaped as query values instead, so
+// values containing & or = cannot add query parameters.
+func expandPath(template string, parameters map[string]string) (string, error) {
+	if !strings.Contains(template, "{") {
+		return template, nil
+	}
+	result := strings.Builder{}
+	remaining := template
+	inQuery := false
+	for {
+		start := strings.Index(remaining, "{")
+		if start < 0 {
+			result.WriteString(remaining)
+			break
+		}
+		end := strings.Index(remaining[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in path %s", template)
+		}
+		end += start
+		name := remaining[start+1 : end]
+		value, ok := parameters[name]
+		if !ok {
+			return "", fmt.Errorf("no value provided for the path parameter %s in path %s", name, template)
+		}
+		inQuery = inQuery || strings.Contains(remaining[:start], "?")
+		result.WriteString(remaining[:start])
+		if inQuery {
+			result.WriteString(url.QueryEscape(value))
+		} else {
+			if value == "" || value == "." || value == ".." {
+				return "", fmt.Errorf("invalid value for the path parameter %s in path %s", name, template)
+			}
+			result.WriteString(url.PathEscape(value))
+		}
+		remaining = remaining[end+1:]
+	}
+	return result.String(), nil
+}
+
+// joinURL appends the path to the base URL, making sure there is exactly one slash between the two. The path may
+// contain a query string, which is merged with the query string of the base URL. An empty path returns the base URL.
+func joinURL(baseURL string, path string) (*url.URL, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	result := *base
+
+	rawPath := path
+	rawQuery := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		rawPath = path[:i]
+		rawQuery = path[i+1:]
+	}
+
+	if rawPath != "" {
+		escapedPath := strings.TrimSuffix(base.EscapedPath(), "/") + "/" + strings.TrimPrefix(rawPath, "/")
+		unescapedPath, err := url.PathUnescape(escapedPath)
+		if err != nil {
+			return nil, err
+		}
+		result.Path = unescapedPath
+		result.RawPath = escapedPath
+	}
+	if rawQuery != "" {
+		if result.RawQuery != "" {
+			result.RawQuery += "&" + rawQuery
+		} else {
+			result.RawQuery = rawQuery
+		}
+	}
+	return &result, nil
+}
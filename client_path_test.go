@@ -0,0 +1,72 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	parameters := map[string]string{
+		"user":  "foo/bar?baz",
+		"key":   "ssh rsa",
+		"dots":  "..",
+		"plain": "foo",
+		"query": "a b&c=d",
+	}
+	for template, expected := range map[string]string{
+		"/users":                    "/users",
+		"/users/{plain}":            "/users/foo",
+		"/users/{user}/keys/{key}":  "/users/foo%2Fbar%3Fbaz/keys/ssh%20rsa",
+		"/users/{plain}?a=b":        "/users/foo?a=b",
+		"/users/{plain}?q={query}":  "/users/foo?q=a+b%26c%3Dd",
+		"/users?q={query}&u={user}": "/users?q=a+b%26c%3Dd&u=foo%2Fbar%3Fbaz",
+		"/users?dir={dots}":         "/users?dir=..",
+	} {
+		t.Run(template, func(t *testing.T) {
+			result, err := expandPath(template, parameters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != expected {
+				t.Fatalf("unexpected path: %s, expected: %s", result, expected)
+			}
+		})
+	}
+	for _, template := range []string{
+		"/users/{missing}",
+		"/users/{dots}/keys",
+		"/users/{plain",
+	} {
+		t.Run(template, func(t *testing.T) {
+			if _, err := expandPath(template, parameters); err == nil {
+				t.Fatalf("invalid path template did not result in an error")
+			}
+		})
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	for _, testCase := range []struct {
+		base     string
+		path     string
+		expected string
+	}{
+		{"http://127.0.0.1:8080", "", "http://127.0.0.1:8080"},
+		{"http://127.0.0.1:8080/", "", "http://127.0.0.1:8080/"},
+		{"http://127.0.0.1:8080", "/users", "http://127.0.0.1:8080/users"},
+		{"http://127.0.0.1:8080/", "/users", "http://127.0.0.1:8080/users"},
+		{"http://127.0.0.1:8080/api", "users", "http://127.0.0.1:8080/api/users"},
+		{"http://127.0.0.1:8080/api/", "/users/", "http://127.0.0.1:8080/api/users/"},
+		{"http://127.0.0.1:8080/api", "/users/foo%2Fbar", "http://127.0.0.1:8080/api/users/foo%2Fbar"},
+		{"http://127.0.0.1:8080/api?token=1", "/users?a=b", "http://127.0.0.1:8080/api/users?token=1&a=b"},
+	} {
+		t.Run(testCase.base+" "+testCase.path, func(t *testing.T) {
+			result, err := joinURL(testCase.base, testCase.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.String() != testCase.expected {
+				t.Fatalf("unexpected URL: %s, expected: %s", result.String(), testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/gorilla/schema"
+)
+
+// hasNoBody returns true if requests with this method are sent without a body. The request object for these methods
+// is encoded into the query string instead if isQueryObject accepts it.
+func hasNoBody(method string) bool {
+	switch method {
+	case http.MethodGet:
+	case http.MethodHead:
+	case http.MethodDelete:
+	default:
+		return false
+	}
+	return true
+}
+
+// isQueryObject returns true if encodeQuery can encode the object. Other request objects, such as generic maps or raw
+// JSON, are sent as the request body even for methods without a body.
+func isQueryObject(object interface{}) bool {
+	switch object.(type) {
+	case nil, url.Values, map[string][]string, map[string]string:
+		return true
+	}
+	value := reflect.ValueOf(object)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return true
+		}
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Struct
+}
+
+// encodeQuery encodes the object into the query values. The object can be url.Values, a string map, or a struct which
+// is encoded using the gorilla/schema encoder, honoring the schema struct tags.
+func encodeQuery(object interface{}, query url.Values) error {
+	switch typedObject := object.(type) {
+	case nil:
+		return nil
+	case url.Values:
+		addQuery(query, typedObject)
+	case map[string][]string:
+		addQuery(query, typedObject)
+	case map[string]string:
+		for name, value := range typedObject {
+			query.Add(name, value)
+		}
+	default:
+		values := url.Values{}
+		if err := schema.NewEncoder().Encode(object, values); err != nil {
+			return err
+		}
+		addQuery(query, values)
+	}
+	return nil
+}
+
+func addQuery(query url.Values, values map[string][]string) {
+	for name, v := range values {
+		for _, value := range v {
+			query.Add(name, value)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/containerssh/log"
+)
+
+// defaultMaxRedirects is the number of redirects followed if the redirect policy does not set a limit.
+const defaultMaxRedirects = 10
+
+// checkRedirect applies the redirect policy to the upcoming request. It is used as the CheckRedirect function of the
+// HTTP client.
+func (c *client) checkRedirect(logger log.Logger) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !c.config.AllowRedirects {
+			return log.NewMessage(
+				EClientRedirectsDisabled,
+				"Redirects disabled, server tried to redirect to %s", req.URL,
+			).Label("redirect", req.URL)
+		}
+		policy := c.config.RedirectPolicy
+		if err := policy.check(req, via); err != nil {
+			err := log.Wrap(err, EClientRedirectNotAllowed, "Redirect to %s not allowed", req.URL).
+				Label("redirect", req.URL)
+			logger.Debug(err)
+			return err
+		}
+		if err := c.destinationChecker.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+			err := log.Wrap(err, EClientDestinationDenied, "Redirect to %s denied by the destination policy", req.URL).
+				Label("redirect", req.URL)
+			logger.Debug(err)
+			return err
+		}
+		if !sameOrigin(req, via[0]) {
+			for _, header := range policy.getSensitiveHeaders() {
+				req.Header.Del(header)
+			}
+		}
+		logger.Debug(
+			log.NewMessage(
+				MClientRedirect, "HTTP redirect to %s", req.URL,
+			).Label("redirect", req.URL),
+		)
+		return nil
+	}
+}
+
+// sameOrigin returns true if the scheme, host and port of the two requests match.
+func sameOrigin(a *http.Request, b *http.Request) bool {
+	return strings.EqualFold(a.URL.Scheme, b.URL.Scheme) && strings.EqualFold(a.URL.Host, b.URL.Host)
+}
+
+// hostMatches returns true if the host matches the pattern. Patterns starting with *. match all subdomains.
+func hostMatches(host string, pattern string) bool {
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
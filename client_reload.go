@@ -0,0 +1,218 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerssh/log"
+)
+
+// certReloader reloads the client certificate and the CA certificate from their files when they change, so
+// certificates rotated on disk are used for new connections without creating a new client. The files are checked at
+// most once per reload interval, when a TLS handshake needs them.
+type certReloader struct {
+	interval   time.Duration
+	certFile   string
+	keyFile    string
+	pkcs12File string
+	passphrase PassphraseConfiguration
+	caFile     string
+	reloadCert bool
+	reloadCA   bool
+	logger     log.Logger
+	// nextVerifyConnection is called with the verified chains after the server certificate has been verified
+	// against the current CA pool, for example to check revocation.
+	nextVerifyConnection func(state tls.ConnectionState) error
+
+	lock    *sync.Mutex
+	checked time.Time
+	// certData, keyData and caData are the file contents last seen, used to detect changes.
+	certData []byte
+	keyData  []byte
+	caData   []byte
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+}
+
+// newCertReloader creates a reloader for the certificate files of the client configuration. It returns nil if
+// reloading is disabled or all certificates are given as inline PEM. Should only be called after config.Validate().
+func newCertReloader(config ClientConfiguration, logger log.Logger) *certReloader {
+	if config.CertReloadInterval == 0 || !strings.HasPrefix(config.URL, "https://") {
+		return nil
+	}
+	r := &certReloader{
+		interval:   config.CertReloadInterval,
+		certFile:   config.ClientCert,
+		keyFile:    config.ClientKey,
+		pkcs12File: config.ClientPKCS12,
+		passphrase: config.KeyPassphrase,
+		caFile:     config.CACert,
+		reloadCert: config.cert != nil &&
+			(config.ClientPKCS12 != "" || !isInlinePem(config.ClientCert) || !isInlinePem(config.ClientKey)),
+		reloadCA: strings.TrimSpace(config.CACert) != "" && !isInlinePem(config.CACert),
+		logger:   logger,
+		lock:     &sync.Mutex{},
+		checked:  time.Now(),
+		cert:     config.cert,
+		caPool:   config.caCertPool,
+	}
+	if !r.reloadCert && !r.reloadCA {
+		return nil
+	}
+	// The files have just been loaded by Validate. If reading them fails here, the next check sees them as changed.
+	if r.reloadCert {
+		r.certData, r.keyData, _ = r.readClientCert()
+	}
+	if r.reloadCA {
+		r.caData, _ = loadPem(r.caFile)
+	}
+	return r
+}
+
+// apply changes the TLS configuration to take the certificates from the reloader. If the CA certificate is reloaded,
+// the built-in verification is replaced by verifyConnection, which verifies against the current CA pool and then
+// calls the VerifyConnection hook that was configured before.
+func (r *certReloader) apply(tlsConfig *tls.Config) {
+	if r.reloadCert {
+		tlsConfig.Certificates = nil
+		tlsConfig.GetClientCertificate = r.getClientCertificate
+	}
+	if r.reloadCA {
+		r.nextVerifyConnection = tlsConfig.VerifyConnection
+		tlsConfig.RootCAs = nil
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = r.verifyConnection
+	}
+}
+
+func (r *certReloader) getClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.refresh()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cert, nil
+}
+
+// verifyConnection verifies the server certificate the same way crypto/tls does, but against the current CA pool.
+func (r *certReloader) verifyConnection(state tls.ConnectionState) error {
+	r.refresh()
+	r.lock.Lock()
+	caPool := r.caPool
+	r.lock.Unlock()
+
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("the server did not present a certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         caPool,
+		Intermediates: intermediates,
+		DNSName:       state.ServerName,
+	})
+	if err != nil {
+		return err
+	}
+	if r.nextVerifyConnection != nil {
+		state.VerifiedChains = chains
+		return r.nextVerifyConnection(state)
+	}
+	return nil
+}
+
+// refresh reloads the files that changed since the last check if the reload interval has passed. If a file cannot be
+// loaded, the previously loaded certificates are kept.
+func (r *certReloader) refresh() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if time.Since(r.checked) < r.interval {
+		return
+	}
+	r.checked = time.Now()
+	if r.reloadCert {
+		r.refreshClientCert()
+	}
+	if r.reloadCA {
+		r.refreshCACert()
+	}
+}
+
+// readClientCert reads the client certificate and key files, or the PKCS #12 file, which is returned as certData.
+func (r *certReloader) readClientCert() (certData []byte, keyData []byte, err error) {
+	if r.pkcs12File != "" {
+		certData, err = loadSecret(r.pkcs12File)
+		return certData, nil, err
+	}
+	if certData, err = loadPem(r.certFile); err != nil {
+		return nil, nil, err
+	}
+	keyData, err = loadPem(r.keyFile)
+	return certData, keyData, err
+}
+
+func (r *certReloader) refreshClientCert() {
+	certData, keyData, err := r.readClientCert()
+	if err != nil {
+		r.reloadFailed(err, "client certificate")
+		return
+	}
+	if bytes.Equal(certData, r.certData) && bytes.Equal(keyData, r.keyData) {
+		return
+	}
+	// The contents are stored before parsing, so a broken file is only reported once per change.
+	r.certData = certData
+	r.keyData = keyData
+	passphrase, err := r.passphrase.load()
+	if err != nil {
+		r.reloadFailed(err, "client certificate")
+		return
+	}
+	var cert *tls.Certificate
+	source := r.certFile
+	if r.pkcs12File != "" {
+		cert, err = parsePKCS12(certData, passphrase)
+		source = r.pkcs12File
+	} else {
+		cert, err = parseKeyPair(certData, keyData, passphrase)
+	}
+	if err != nil {
+		r.reloadFailed(err, "client certificate")
+		return
+	}
+	r.cert = cert
+	r.logger.Info(log.NewMessage(MClientCertificateReloaded, "Reloaded the client certificate from %s", source))
+}
+
+func (r *certReloader) refreshCACert() {
+	caData, err := loadPem(r.caFile)
+	if err != nil {
+		r.reloadFailed(err, "CA certificate")
+		return
+	}
+	if bytes.Equal(caData, r.caData) {
+		return
+	}
+	r.caData = caData
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caData) {
+		r.reloadFailed(fmt.Errorf("no valid certificate found in %s", r.caFile), "CA certificate")
+		return
+	}
+	r.caPool = caPool
+	r.logger.Info(log.NewMessage(MClientCertificateReloaded, "Reloaded the CA certificate from %s", r.caFile))
+}
+
+func (r *certReloader) reloadFailed(err error, what string) {
+	r.logger.Warning(log.Wrap(
+		err,
+		EClientCertificateReloadFailed,
+		"Failed to reload the %s, using the previously loaded one",
+		what,
+	))
+}
@@ -0,0 +1,104 @@
+package http_test
+
+import (
+	"io/ioutil"
+	goHttp "net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+)
+
+type rotatingCertificates struct {
+	ca         *certs.CA
+	serverCert *certs.Certificate
+	clientCert *certs.Certificate
+}
+
+func newRotatingCertificates(t *testing.T) rotatingCertificates {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := ca.NewServerCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := ca.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rotatingCertificates{ca, serverCert, clientCert}
+}
+
+func (r rotatingCertificates) serverConfig() http.ServerConfiguration {
+	_, serverConfig := createClientServerConfig()
+	serverConfig.Cert = r.serverCert.CertificatePEM()
+	serverConfig.Key = r.serverCert.PrivateKeyPEM()
+	serverConfig.ClientCACert = r.ca.CertificatePEM()
+	return serverConfig
+}
+
+func (r rotatingCertificates) writeClientFiles(t *testing.T, dir string) {
+	for file, content := range map[string]string{
+		"ca.crt":     r.ca.CertificatePEM(),
+		"client.crt": r.clientCert.CertificatePEM(),
+		"client.key": r.clientCert.PrivateKeyPEM(),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newRotatingCertificates(t)
+	second := newRotatingCertificates(t)
+	first.writeClientFiles(t, dir)
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = "https://127.0.0.1:8080"
+	clientConfig.CACert = filepath.Join(dir, "ca.crt")
+	clientConfig.ClientCert = filepath.Join(dir, "client.crt")
+	clientConfig.ClientKey = filepath.Join(dir, "client.key")
+	clientConfig.CertReloadInterval = 100 * time.Millisecond
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	})
+	request := func(serverConfig http.ServerConfiguration) error {
+		stop, err := startServer(t, serverConfig, handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		_, err = client.Head("/")
+		return err
+	}
+
+	assert.NoError(t, request(first.serverConfig()))
+
+	// The server switched to the second CA, but the client files have not been rotated yet.
+	assert.Error(t, request(second.serverConfig()))
+
+	// A broken file is ignored and the previously loaded certificates stay in use.
+	if err := ioutil.WriteFile(filepath.Join(dir, "ca.crt"), []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, request(first.serverConfig()))
+
+	second.writeClientFiles(t, dir)
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, request(second.serverConfig()))
+}
@@ -6,25 +6,134 @@ const EFailureEncodeFailed = "HTTP_CLIENT_ENCODE_FAILED"
 // This message indicates a connection failure on the network level.
 const EFailureConnectionFailed = "HTTP_CLIENT_CONNECTION_FAILED"
 
-// This message indicates that decoding the JSON response has failed. The status code is set for this
+// This message indicates that decoding the response has failed. The status code is set for this
 // code.
 const EFailureDecodeFailed = "HTTP_CLIENT_DECODE_FAILED"
 
+// This message indicates that the server responded with a Content-Type the client has no decoder for. The status
+// code is set for this code.
+const EClientUnsupportedContentType = "HTTP_CLIENT_UNSUPPORTED_CONTENT_TYPE"
+
+// This message indicates that the server responded with a status code that was not in the list of expected status
+// codes for the request. The status code is set for this code.
+const EClientUnexpectedStatus = "HTTP_CLIENT_UNEXPECTED_STATUS"
+
+// This message indicates that the request path could not be built, for example because a path parameter is missing or
+// has an invalid value. This is usually a bug in the calling code.
+const EClientInvalidPath = "HTTP_CLIENT_INVALID_PATH"
+
+// This message indicates that the timeout passed to WithTimeout is lower than 100ms or lower than one of the configured
+// timeouts of the individual request phases. This is usually a bug in the calling code.
+const EClientInvalidTimeout = "HTTP_CLIENT_INVALID_TIMEOUT"
+
 // This message indicates that ContainerSSH is not following a HTTP redirect sent by the server. Use the allowRedirects
 // option to allow following HTTP redirects.
 const EClientRedirectsDisabled = "HTTP_CLIENT_REDIRECTS_DISABLED"
 
+// This message indicates that ContainerSSH is not following a HTTP redirect because the redirect policy does not allow
+// it, for example because the redirect points to a different host or downgrades from https:// to http://.
+const EClientRedirectNotAllowed = "HTTP_CLIENT_REDIRECT_NOT_ALLOWED"
+
+// This message indicates that the client refused to connect to an IP address because it is not allowed by the
+// destination policy. Loopback and link-local addresses are denied unless explicitly allowed.
+const EClientDestinationDenied = "HTTP_CLIENT_DESTINATION_DENIED"
+
+// This message indicates that the client could not write the cookie jar file. Cookies are still kept in memory, but
+// will be lost on restart.
+const EClientCookieJarSaveFailed = "HTTP_CLIENT_COOKIE_JAR_SAVE_FAILED"
+
+// The client is in fixture replay mode and no recorded response matches the request. Record the fixtures again or
+// check the matching configuration.
+const EClientFixtureNotFound = "HTTP_CLIENT_FIXTURE_NOT_FOUND"
+
+// The client is in fixture record mode and could not write the fixture file.
+const EClientFixtureSaveFailed = "HTTP_CLIENT_FIXTURE_SAVE_FAILED"
+
+// The client could not decode the data of a Server-Sent Event into the value provided for its type. The event is
+// skipped.
+const EClientEventDecodeFailed = "HTTP_CLIENT_EVENT_DECODE_FAILED"
+
+// The connection to a Server-Sent Events stream was lost. The client will reconnect after the retry interval.
+const EClientEventStreamDisconnected = "HTTP_CLIENT_EVENT_STREAM_DISCONNECTED"
+
+// This message indicates that the client is connecting to a Server-Sent Events stream.
+const MClientEventStreamConnect = "HTTP_CLIENT_EVENT_STREAM_CONNECT"
+
+// The client health check failed. The error contains the stage that failed, which can be the DNS resolution, the TCP
+// connection, the TLS handshake or the HTTP probe request.
+const EClientHealthCheckFailed = "HTTP_CLIENT_HEALTH_CHECK_FAILED"
+
+// This message indicates that the client health check passed all stages.
+const MClientHealthCheckPassed = "HTTP_CLIENT_HEALTH_CHECK_PASSED"
+
 // This message indicates that a HTTP request is being sent from ContainerSSH
 const MClientRequest = "HTTP_CLIENT_REQUEST"
 
+// This message contains the headers and body of a HTTP request with sensitive values redacted. It is only logged if
+// debug logging is enabled in the client configuration.
+const MClientRequestDebug = "HTTP_CLIENT_REQUEST_DEBUG"
+
 // This message indicates that the server responded with a HTTP redirect.
 const MClientRedirect = "HTTP_CLIENT_REDIRECT"
 
 // This message indicates that ContainerSSH received a HTTP response from a server.
 const MClientResponse = "HTTP_CLIENT_RESPONSE"
 
+// This message contains the headers and body of a HTTP response with sensitive values redacted. It is only logged if
+// debug logging is enabled in the client configuration.
+const MClientResponseDebug = "HTTP_CLIENT_RESPONSE_DEBUG"
+
+// This message indicates that the original HTTP request did not receive a response within the hedging delay and a
+// second request is being sent. The first good response will be used.
+const MClientHedgedRequest = "HTTP_CLIENT_HEDGED_REQUEST"
+
 // The HTTP server failed to write the response.
 const MServerResponseWriteFailed = "HTTP_SERVER_RESPONSE_WRITE_FAILED"
 
 // The HTTP server failed to encode the response object. This is a bug, please report it.
 const MServerEncodeFailed = "HTTP_SERVER_ENCODE_FAILED"
+
+// A queued request could not be delivered. The queue will retry the request after a backoff.
+const EQueueDeliveryFailed = "HTTP_QUEUE_DELIVERY_FAILED"
+
+// A queued request could not be delivered within the maximum number of attempts, or the server rejected it with a
+// 4xx status code. The request has been moved to the dead subdirectory of the queue directory.
+const EQueueDeadLettered = "HTTP_QUEUE_DEAD_LETTERED"
+
+// The queue could not write or remove a request in the queue directory. Check the permissions and free space of the
+// queue directory.
+const EQueuePersistFailed = "HTTP_QUEUE_PERSIST_FAILED"
+
+// This message indicates that a queued request has been delivered.
+const MQueueDelivered = "HTTP_QUEUE_DELIVERED"
+
+// The peer certificate or a certificate in its chain has been revoked according to a CRL or the OCSP responder. The
+// TLS connection is rejected.
+const ETLSCertificateRevoked = "HTTP_TLS_CERTIFICATE_REVOKED"
+
+// The revocation status of a certificate could not be determined, for example because a CRL file could not be read,
+// the CRL has expired, or the OCSP responder did not answer. The connection is rejected because hard-fail mode is
+// configured.
+const ETLSRevocationUnknown = "HTTP_TLS_REVOCATION_UNKNOWN"
+
+// The revocation status of a certificate could not be determined. The connection is allowed because soft-fail mode
+// is configured.
+const ETLSRevocationSoftFail = "HTTP_TLS_REVOCATION_SOFT_FAIL"
+
+// The client could not reload its certificate, key or CA certificate after the files changed. The client keeps using
+// the previously loaded certificates. Check that the files are readable and that the certificate and key match.
+const EClientCertificateReloadFailed = "HTTP_CLIENT_CERTIFICATE_RELOAD_FAILED"
+
+// This message indicates that the client loaded a changed certificate, key or CA certificate file.
+const MClientCertificateReloaded = "HTTP_CLIENT_CERTIFICATE_RELOADED"
+
+// This message indicates the TLS version, cipher suite and key exchange negotiated for a connection.
+const MTLSVersionNegotiated = "HTTP_TLS_VERSION_NEGOTIATED"
+
+// A connection negotiated TLS 1.0 or 1.1, which are insecure. This is only possible if AllowInsecureTLSVersions is
+// enabled. Upgrade the peer to TLS 1.2 or newer.
+const ETLSInsecureVersionNegotiated = "HTTP_TLS_INSECURE_VERSION_NEGOTIATED"
+
+// The configured TLS versions, cipher suites and certificate do not fit together, so some or all TLS connections
+// cannot be negotiated. Check the TLS profile and the explicitly configured cipher suites and versions.
+const ETLSConfigurationMismatch = "HTTP_TLS_CONFIGURATION_MISMATCH"
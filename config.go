@@ -1,11 +1,13 @@
 package http
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -15,31 +17,162 @@ import (
 type TLSVersion string
 
 const (
+	// TLSVersion10 is TLS 1.0. It is insecure and can only be used with AllowInsecureTLSVersions.
+	TLSVersion10 TLSVersion = "1.0"
+	// TLSVersion11 is TLS 1.1. It is insecure and can only be used with AllowInsecureTLSVersions.
+	TLSVersion11 TLSVersion = "1.1"
 	TLSVersion12 TLSVersion = "1.2"
 	TLSVersion13 TLSVersion = "1.3"
 )
 
+var tlsVersionToID = map[TLSVersion]uint16{
+	TLSVersion10: tls.VersionTLS10,
+	TLSVersion11: tls.VersionTLS11,
+	TLSVersion12: tls.VersionTLS12,
+	TLSVersion13: tls.VersionTLS13,
+}
+
 // Validate validates the TLS version
 func (t TLSVersion) Validate() error {
-	switch t {
-	case TLSVersion13:
-		fallthrough
-	case TLSVersion12:
-		return nil
-	default:
+	if _, ok := tlsVersionToID[t]; !ok {
 		return fmt.Errorf("unsupported TLS version: %s", t)
 	}
+	return nil
 }
 
 func (t TLSVersion) getTLSVersion() uint16 {
-	switch t {
-	case TLSVersion13:
-		return tls.VersionTLS12
-	case TLSVersion12:
-		return tls.VersionTLS12
+	if version, ok := tlsVersionToID[t]; ok {
+		return version
+	}
+	panic(fmt.Errorf("invalid TLS version: %s", t))
+}
+
+// getMaxTLSVersion returns the TLS version to use as a maximum version. An empty version means that there is no
+// maximum, which is represented by 0 in tls.Config.
+func (t TLSVersion) getMaxTLSVersion() uint16 {
+	if t == "" {
+		return 0
+	}
+	return t.getTLSVersion()
+}
+
+func (t TLSVersion) isInsecure() bool {
+	return t == TLSVersion10 || t == TLSVersion11
+}
+
+// validateTLSVersions validates the minimum and maximum TLS versions of a configuration.
+func validateTLSVersions(minVersion TLSVersion, maxVersion TLSVersion, allowInsecure bool) error {
+	if err := minVersion.Validate(); err != nil {
+		return err
+	}
+	if maxVersion != "" {
+		if err := maxVersion.Validate(); err != nil {
+			return fmt.Errorf("invalid maximum TLS version (%w)", err)
+		}
+		if maxVersion.getTLSVersion() < minVersion.getTLSVersion() {
+			return fmt.Errorf("the maximum TLS version %s is lower than the minimum TLS version %s", maxVersion, minVersion)
+		}
+	}
+	if minVersion.isInsecure() && !allowInsecure {
+		return fmt.Errorf("TLS version %s is insecure and requires AllowInsecureTLSVersions", minVersion)
+	}
+	return nil
+}
+
+// TLSProfile is a named set of TLS versions, cipher suites and curves, following the Mozilla server side TLS
+// recommendations. Explicitly configured versions, cipher suites and curves override the profile.
+type TLSProfile string
+
+const (
+	// TLSProfileDefault uses the modern profile.
+	TLSProfileDefault TLSProfile = ""
+	// TLSProfileModern only allows TLS 1.3.
+	TLSProfileModern TLSProfile = "modern"
+	// TLSProfileIntermediate allows TLS 1.2 and 1.3 with forward secret AEAD cipher suites.
+	TLSProfileIntermediate TLSProfile = "intermediate"
+	// TLSProfileLegacy also allows TLS 1.0 and 1.1 with CBC cipher suites for old peers. It requires
+	// AllowInsecureTLSVersions.
+	TLSProfileLegacy TLSProfile = "legacy"
+)
+
+// Validate validates the TLS profile.
+func (p TLSProfile) Validate() error {
+	if _, ok := tlsProfiles[p.get()]; !ok {
+		return fmt.Errorf("unsupported TLS profile: %s", p)
+	}
+	return nil
+}
+
+func (p TLSProfile) get() TLSProfile {
+	if p == TLSProfileDefault {
+		return TLSProfileModern
+	}
+	return p
+}
+
+// HTTPVersion is the HTTP protocol version to use.
+type HTTPVersion string
+
+const (
+	// HTTPVersionDefault uses HTTP/1.1 on the client. On the server, it uses HTTP/1.1, as well as HTTP/2 over TLS if
+	// the cipher suites allow it.
+	HTTPVersionDefault HTTPVersion = ""
+	// HTTPVersion11 only uses HTTP/1.1.
+	HTTPVersion11 HTTPVersion = "1.1"
+	// HTTPVersion2 uses HTTP/2 over TLS, negotiated using ALPN, with a fallback to HTTP/1.1.
+	HTTPVersion2 HTTPVersion = "2"
+	// HTTPVersionH2C uses unencrypted HTTP/2 with prior knowledge (h2c).
+	HTTPVersionH2C HTTPVersion = "h2c"
+)
+
+// Validate validates the HTTP version.
+func (v HTTPVersion) Validate() error {
+	switch v {
+	case HTTPVersionDefault:
+	case HTTPVersion11:
+	case HTTPVersion2:
+	case HTTPVersionH2C:
+	default:
+		return fmt.Errorf("unsupported HTTP version: %s", v)
+	}
+	return nil
+}
+
+func (v HTTPVersion) getClientProtocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	switch v {
+	case HTTPVersionDefault:
+		fallthrough
+	case HTTPVersion11:
+		protocols.SetHTTP1(true)
+	case HTTPVersion2:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case HTTPVersionH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		panic(fmt.Errorf("invalid HTTP version: %s", v))
+	}
+	return protocols
+}
+
+// getServerProtocols returns the protocols the server offers. tlsSupportsHTTP2 indicates if the TLS cipher suites
+// allow HTTP/2, which the default version only offers if they do.
+func (v HTTPVersion) getServerProtocols(tlsSupportsHTTP2 bool) *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	switch v {
+	case HTTPVersion11:
+	case HTTPVersionDefault:
+		protocols.SetHTTP2(tlsSupportsHTTP2)
+	case HTTPVersion2:
+		protocols.SetHTTP2(true)
+	case HTTPVersionH2C:
+		protocols.SetUnencryptedHTTP2(true)
 	default:
-		panic(fmt.Errorf("invalid TLS version: %s", t))
+		panic(fmt.Errorf("invalid HTTP version: %s", v))
 	}
+	return protocols
 }
 
 // ECDHCurveList is a list of supported ECDHCurve
@@ -71,25 +204,27 @@ const (
 	ECDHCurveSecP521r1    ECDHCurve = "secp521r1"
 )
 
+// Hybrid post-quantum key exchange algorithms, combining an elliptic curve with ML-KEM. They are only negotiated with
+// TLS 1.3. The hybrids with the NIST curves are only available when built with Go 1.26 or newer.
+const (
+	ECDHCurveX25519MLKEM768    ECDHCurve = "x25519mlkem768"
+	ECDHCurveX25519MLKEM768Alt ECDHCurve = "X25519MLKEM768"
+)
+
 var curveToID = map[ECDHCurve]tls.CurveID{
-	ECDHCurveX25519:       tls.X25519,
-	ECDHCurveX25519Alt:    tls.X25519,
-	ECDHCurveSecP256r1:    tls.CurveP256,
-	ECDHCurveSecP256r1Alt: tls.CurveP256,
-	ECDHCurveSecP384r1:    tls.CurveP384,
-	ECDHCurveSecP521r1:    tls.CurveP521,
+	ECDHCurveX25519:            tls.X25519,
+	ECDHCurveX25519Alt:         tls.X25519,
+	ECDHCurveSecP256r1:         tls.CurveP256,
+	ECDHCurveSecP256r1Alt:      tls.CurveP256,
+	ECDHCurveSecP384r1:         tls.CurveP384,
+	ECDHCurveSecP521r1:         tls.CurveP521,
+	ECDHCurveX25519MLKEM768:    tls.X25519MLKEM768,
+	ECDHCurveX25519MLKEM768Alt: tls.X25519MLKEM768,
 }
 
 // Validate validates the TLS curve for a valid value.
 func (c ECDHCurve) Validate() error {
-	switch c {
-	case ECDHCurveX25519:
-	case ECDHCurveX25519Alt:
-	case ECDHCurveSecP256r1:
-	case ECDHCurveSecP256r1Alt:
-	case ECDHCurveSecP384r1:
-	case ECDHCurveSecP521r1:
-	default:
+	if _, ok := curveToID[c]; !ok {
 		return fmt.Errorf("invalid ECDH curve: %s", c)
 	}
 	return nil
@@ -235,6 +370,18 @@ const (
 	IANA_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305       CipherSuite = "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305"
 	OpenSSL_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305    CipherSuite = "ECDHE-RSA-CHACHA20-POLY1305"
 	GnuTLS_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305     CipherSuite = "TLS_ECDHE_RSA_CHACHA20_POLY1305"
+	IANA_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA       CipherSuite = "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"
+	OpenSSL_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA    CipherSuite = "ECDHE-ECDSA-AES128-SHA"
+	GnuTLS_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA     CipherSuite = "TLS_ECDHE_ECDSA_AES_128_CBC_SHA1"
+	IANA_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA         CipherSuite = "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"
+	OpenSSL_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA      CipherSuite = "ECDHE-RSA-AES128-SHA"
+	GnuTLS_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA       CipherSuite = "TLS_ECDHE_RSA_AES_128_CBC_SHA1"
+	IANA_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA       CipherSuite = "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"
+	OpenSSL_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA    CipherSuite = "ECDHE-ECDSA-AES256-SHA"
+	GnuTLS_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA     CipherSuite = "TLS_ECDHE_ECDSA_AES_256_CBC_SHA1"
+	IANA_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA         CipherSuite = "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA"
+	OpenSSL_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA      CipherSuite = "ECDHE-RSA-AES256-SHA"
+	GnuTLS_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA       CipherSuite = "TLS_ECDHE_RSA_AES_256_CBC_SHA1"
 )
 
 var stringToCipherSuite = map[CipherSuite]uint16{
@@ -261,6 +408,19 @@ var stringToCipherSuite = map[CipherSuite]uint16{
 	GnuTLS_TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
 	GnuTLS_TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
 	GnuTLS_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+
+	IANA_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	IANA_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	IANA_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	IANA_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	OpenSSL_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA: tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	OpenSSL_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:   tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	OpenSSL_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA: tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	OpenSSL_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:   tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	GnuTLS_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:  tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	GnuTLS_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:    tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	GnuTLS_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:  tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	GnuTLS_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:    tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
 }
 
 // Validate validates if the cipher suite is supported.
@@ -289,41 +449,120 @@ type ClientConfiguration struct {
 	// AllowRedirects sets if the client should honor HTTP redirects. Defaults to false.
 	AllowRedirects bool `json:"allowRedirects" yaml:"allowRedirects" comment:""`
 
+	// RedirectPolicy restricts which redirects are followed if AllowRedirects is enabled.
+	RedirectPolicy RedirectPolicy `json:"redirectPolicy" yaml:"redirectPolicy"`
+
+	// DestinationPolicy restricts which IP addresses the client may connect to.
+	DestinationPolicy DestinationPolicy `json:"destinationPolicy" yaml:"destinationPolicy"`
+
+	// CookieJar configures storing cookies between requests, for example for session affinity.
+	CookieJar CookieJarConfiguration `json:"cookieJar" yaml:"cookieJar"`
+
+	// Debug configures logging request and response headers and bodies at the debug level.
+	Debug DebugConfiguration `json:"debug" yaml:"debug"`
+
+	// Fixtures configures recording requests and responses to a file, or replaying responses from it instead of
+	// sending requests to the server. This is intended for tests.
+	Fixtures FixtureConfiguration `json:"fixtures" yaml:"fixtures"`
+
 	// Timeout is the time the client should wait for a response.
 	Timeout time.Duration `json:"timeout" yaml:"timeout" comment:"HTTP call timeout." default:"2s"`
 
-	// CACert is either the CA certificate to expect on the server in PEM format
-	//         or the name of a file containing the PEM.
+	// DialTimeout is the maximum time to wait for the TCP connection to be established. Zero means the connection is
+	// only limited by Timeout.
+	DialTimeout time.Duration `json:"dialTimeout" yaml:"dialTimeout" comment:"TCP connection timeout. Zero means only Timeout applies."`
+
+	// TLSHandshakeTimeout is the maximum time to wait for the TLS handshake. Zero means the handshake is only limited
+	// by Timeout.
+	TLSHandshakeTimeout time.Duration `json:"tlsHandshakeTimeout" yaml:"tlsHandshakeTimeout" comment:"TLS handshake timeout. Zero means only Timeout applies."`
+
+	// ResponseHeaderTimeout is the maximum time to wait for the response headers after the request has been sent.
+	// Zero means waiting for the headers is only limited by Timeout.
+	ResponseHeaderTimeout time.Duration `json:"responseHeaderTimeout" yaml:"responseHeaderTimeout" comment:"Timeout for receiving the response headers after the request is sent. Zero means only Timeout applies."`
+
+	// IdleConnectionTimeout is the time after which idle keep-alive connections are closed. Zero means idle
+	// connections are kept open indefinitely.
+	IdleConnectionTimeout time.Duration `json:"idleConnectionTimeout" yaml:"idleConnectionTimeout" comment:"Time after which idle keep-alive connections are closed." default:"90s"`
+
+	// ExpectContinueTimeout is the time to wait for a 100-continue response if the request has an
+	// "Expect: 100-continue" header. Zero means the body is sent immediately.
+	ExpectContinueTimeout time.Duration `json:"expectContinueTimeout" yaml:"expectContinueTimeout" comment:"Time to wait for a 100-continue response before sending the body."`
+
+	// HTTPVersion is the HTTP protocol version to use. Use "2" for HTTP/2 over TLS and "h2c" for unencrypted HTTP/2
+	// with prior knowledge.
+	HTTPVersion HTTPVersion `json:"httpVersion" yaml:"httpVersion" comment:"HTTP version to use: 1.1, 2 (HTTP/2 over TLS), or h2c (unencrypted HTTP/2)." default:"1.1"`
+
+	// CACert is either the CA certificate to expect on the server in PEM format, the name of a file containing the
+	// PEM, or a secret reference.
 	CACert string `json:"cacert" yaml:"cacert" comment:"CA certificate in PEM format to use for host verification. Note: due to a bug in Go on Windows this has to be explicitly provided."`
 
-	// ClientCert is a PEM containing an x509 certificate to present to the server or a file name containing the PEM.
+	// ClientCert is a PEM containing an x509 certificate to present to the server, a file name containing the PEM, or
+	// a secret reference.
 	ClientCert string `json:"cert" yaml:"cert" comment:"Client certificate file in PEM format."`
 
-	// ClientKey is a PEM containing a private key to use to connect the server or a file name containing the PEM.
+	// ClientKey is a PEM containing a private key to use to connect the server, a file name containing the PEM, or a
+	// secret reference. The key may be an encrypted PKCS #8 key, which is decrypted with KeyPassphrase.
 	ClientKey string `json:"key" yaml:"key" comment:"Client key file in PEM format."`
 
-	// TLSVersion is the minimum TLS version to use.
-	TLSVersion TLSVersion `json:"tlsVersion" yaml:"tlsVersion" default:"1.3"`
+	// ClientPKCS12 is the name of a PKCS #12 (PFX) file or a secret reference containing the client certificate, its
+	// chain and the private key. It can be used instead of ClientCert and ClientKey and is decrypted with
+	// KeyPassphrase.
+	ClientPKCS12 string `json:"pkcs12" yaml:"pkcs12" comment:"Client certificate and key in a PKCS #12 file."`
+
+	// KeyPassphrase is the passphrase of an encrypted ClientKey or of ClientPKCS12.
+	KeyPassphrase PassphraseConfiguration `json:"keyPassphrase" yaml:"keyPassphrase"`
+
+	// TLSProfile selects the TLS versions, cipher suites and curves to use. TLSVersion, ECDHCurves and
+	// CipherSuites override the profile if set. Defaults to modern.
+	TLSProfile TLSProfile `json:"tlsProfile" yaml:"tlsProfile" comment:"TLS profile: modern, intermediate or legacy." default:"modern"`
+
+	// TLSVersion is the minimum TLS version to use. Empty means the minimum version of the TLS profile.
+	TLSVersion TLSVersion `json:"tlsVersion" yaml:"tlsVersion" comment:"Minimum TLS version. Empty means the version of the TLS profile."`
+
+	// MaxTLSVersion is the maximum TLS version to use. Empty means the newest version supported.
+	MaxTLSVersion TLSVersion `json:"maxTLSVersion" yaml:"maxTLSVersion" comment:"Maximum TLS version. Empty means the newest version supported."`
+
+	// AllowInsecureTLSVersions allows setting TLSVersion to the insecure TLS versions 1.0 and 1.1.
+	AllowInsecureTLSVersions bool `json:"allowInsecureTLSVersions" yaml:"allowInsecureTLSVersions" comment:"Allow TLS 1.0 and 1.1 as the minimum TLS version."`
+
+	// ECDHCurves is the list of curve algorithms to support. Empty means the curves of the TLS profile.
+	ECDHCurves ECDHCurveList `json:"curves" yaml:"curves" comment:"ECDH curves. Empty means the curves of the TLS profile."`
+
+	// CipherSuites is a list of supported cipher suites. Empty means the cipher suites of the TLS profile.
+	CipherSuites CipherSuiteList `json:"cipher" yaml:"cipher" comment:"Cipher suites. Empty means the cipher suites of the TLS profile."`
 
-	// ECDHCurves is the list of curve algorithms to support.
-	ECDHCurves ECDHCurveList `json:"curves" yaml:"curves" default:"[\"x25519\",\"secp256r1\",\"secp384r1\",\"secp521r1\"]"`
+	// Revocation configures checking the server certificate against CRLs and an OCSP responder.
+	Revocation RevocationConfiguration `json:"revocation" yaml:"revocation"`
 
-	// CipherSuites is a list of supported cipher suites.
-	CipherSuites CipherSuiteList `json:"cipher" yaml:"cipher" default:"[\"TLS_AES_128_GCM_SHA256\",\"TLS_AES_256_GCM_SHA384\",\"TLS_CHACHA20_POLY1305_SHA256\",\"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256\",\"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256\"]"`
+	// CertReloadInterval is the interval at which the CACert, ClientCert and ClientKey files are checked for changes.
+	// Changed files are loaded for new connections without creating a new client. Zero means the files are only
+	// loaded once. Certificates given as PEM instead of a file name are never reloaded.
+	CertReloadInterval time.Duration `json:"certReloadInterval" yaml:"certReloadInterval" comment:"Interval for checking the certificate files for changes. Zero disables reloading."`
 
 	// RequestEncoding is the means by which the request body is encoded. It defaults to JSON encoding.
 	RequestEncoding RequestEncoding `json:"-" yaml:"-"`
 
+	// Hedging configures sending a second request for idempotent methods if the first one is slow.
+	Hedging HedgingConfiguration `json:"hedging" yaml:"hedging"`
+
+	// HealthCheck configures the probe request sent by the HealthCheck method of the client.
+	HealthCheck HealthCheckConfiguration `json:"healthCheck" yaml:"healthCheck"`
+
 	// caCertPool is for internal use only. It contains the loaded CA certificates after Validate.
 	caCertPool *x509.CertPool `json:"-" yaml:"-"`
 
 	// cert is for internal use only. It contains the loaded TLS key and certificate after Validate.
 	// We are adding the JSON and YAML tags to conform to the Operator SDK requirements to tag all fields.
 	cert *tls.Certificate `json:"-" yaml:"-"`
+
+	// tlsWarnings is for internal use only. It contains the TLS configuration problems found by Validate.
+	tlsWarnings []string `json:"-" yaml:"-"`
 }
 
-// Validate validates the client configuration and returns an error if it is invalid.
+// Validate validates the client configuration and returns an error if it is invalid. Combinations of TLS settings
+// that are valid but keep some or all connections from being negotiated are returned by TLSWarnings afterwards.
 func (c *ClientConfiguration) Validate() error {
+	c.tlsWarnings = nil
 	_, err := url.ParseRequestURI(c.URL)
 	if err != nil {
 		return fmt.Errorf("invalid URL: %s", c.URL)
@@ -331,6 +570,9 @@ func (c *ClientConfiguration) Validate() error {
 	if c.Timeout < 100*time.Millisecond {
 		return fmt.Errorf("timeout value %s is too low, must be at least 100ms", c.Timeout.String())
 	}
+	if err := c.validateTimeouts(); err != nil {
+		return err
+	}
 
 	if err := c.validateCACert(); err != nil {
 		return err
@@ -340,21 +582,131 @@ func (c *ClientConfiguration) Validate() error {
 		return err
 	}
 
+	if err := c.HTTPVersion.Validate(); err != nil {
+		return err
+	}
+	if c.HTTPVersion == HTTPVersion2 && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("HTTP/2 requires a https:// URL, use h2c for unencrypted HTTP/2")
+	}
+	if c.HTTPVersion == HTTPVersionH2C && !strings.HasPrefix(c.URL, "http://") {
+		return fmt.Errorf("h2c requires a http:// URL, use HTTP version 2 for HTTP/2 over TLS")
+	}
+
+	if err := c.RedirectPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid redirect policy (%w)", err)
+	}
+
+	if err := c.DestinationPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid destination policy (%w)", err)
+	}
+
+	if err := c.CookieJar.Validate(); err != nil {
+		return fmt.Errorf("invalid cookie jar configuration (%w)", err)
+	}
+
+	if err := c.Debug.Validate(); err != nil {
+		return fmt.Errorf("invalid debug configuration (%w)", err)
+	}
+
+	if err := c.Fixtures.Validate(); err != nil {
+		return fmt.Errorf("invalid fixture configuration (%w)", err)
+	}
+
+	if err := c.Hedging.Validate(c.URL, c.Timeout); err != nil {
+		return fmt.Errorf("invalid hedging configuration (%w)", err)
+	}
+
+	if err := c.HealthCheck.Validate(); err != nil {
+		return fmt.Errorf("invalid health check configuration (%w)", err)
+	}
+
 	if strings.HasPrefix(c.URL, "https://") {
-		if err := c.TLSVersion.Validate(); err != nil {
+		if err := c.TLSProfile.Validate(); err != nil {
+			return err
+		}
+		settings := c.getTLSSettings()
+		if err := validateTLSVersions(settings.minVersion, c.MaxTLSVersion, c.AllowInsecureTLSVersions); err != nil {
 			return fmt.Errorf("invalid TLS version (%w)", err)
 		}
-		if err := c.ECDHCurves.Validate(); err != nil {
+		if err := settings.curves.Validate(); err != nil {
 			return fmt.Errorf("invalid curve algorithms (%w)", err)
 		}
-		if err := c.CipherSuites.Validate(); err != nil {
+		if err := settings.cipherSuites.Validate(); err != nil {
 			return fmt.Errorf("invalid cipher suites (%w)", err)
 		}
+		// The client certificate is only used for signing, so its key type does not depend on the cipher suite.
+		c.tlsWarnings = settings.warnings(nil)
+	}
+
+	if err := c.Revocation.Validate(); err != nil {
+		return fmt.Errorf("invalid revocation configuration (%w)", err)
+	}
+	if c.Revocation.enabled() && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("revocation checking requires an https:// URL")
+	}
+	if c.CertReloadInterval < 0 {
+		return fmt.Errorf("the certificate reload interval cannot be negative")
 	}
 
 	return c.validateClientCert()
 }
 
+// TLSWarnings returns the TLS configuration problems found by the last call to Validate, such as allowed TLS versions
+// none of the configured cipher suites supports.
+func (c *ClientConfiguration) TLSWarnings() []string {
+	return c.tlsWarnings
+}
+
+// getTLSSettings returns the TLS settings of the profile, overridden by the explicitly configured ones.
+func (c *ClientConfiguration) getTLSSettings() tlsSettings {
+	return c.TLSProfile.getSettings(c.TLSVersion, c.MaxTLSVersion, c.CipherSuites, c.ECDHCurves)
+}
+
+func (c *ClientConfiguration) validateTimeouts() error {
+	return c.validatePhaseTimeouts(c.Timeout)
+}
+
+// validateRequestTimeout validates a timeout passed to WithTimeout, which replaces Timeout for a single request and
+// must satisfy the same rules.
+func (c *ClientConfiguration) validateRequestTimeout(timeout time.Duration) error {
+	if timeout < 100*time.Millisecond {
+		return fmt.Errorf("timeout value %s is too low, must be at least 100ms", timeout.String())
+	}
+	return c.validatePhaseTimeouts(timeout)
+}
+
+// validatePhaseTimeouts validates the timeouts of the individual phases of a request, which cannot be higher than the
+// timeout of the entire request.
+func (c *ClientConfiguration) validatePhaseTimeouts(total time.Duration) error {
+	for _, timeout := range []struct {
+		name       string
+		value      time.Duration
+		belowTotal bool
+	}{
+		{"dial timeout", c.DialTimeout, true},
+		{"TLS handshake timeout", c.TLSHandshakeTimeout, true},
+		{"response header timeout", c.ResponseHeaderTimeout, true},
+		{"idle connection timeout", c.IdleConnectionTimeout, false},
+		{"expect continue timeout", c.ExpectContinueTimeout, true},
+	} {
+		if timeout.value == 0 {
+			continue
+		}
+		if timeout.value < 100*time.Millisecond {
+			return fmt.Errorf("%s value %s is too low, must be at least 100ms", timeout.name, timeout.value.String())
+		}
+		if timeout.belowTotal && timeout.value > total {
+			return fmt.Errorf(
+				"%s value %s is higher than the timeout %s",
+				timeout.name,
+				timeout.value.String(),
+				total.String(),
+			)
+		}
+	}
+	return nil
+}
+
 func (c *ClientConfiguration) validateClientCert() error {
 	if c.ClientCert != "" && c.ClientKey == "" {
 		return fmt.Errorf("client certificate provided without client key")
@@ -362,20 +714,19 @@ func (c *ClientConfiguration) validateClientCert() error {
 		return fmt.Errorf("client key provided without client certificate")
 	}
 
-	if c.ClientCert != "" && c.ClientKey != "" {
-		clientCert, err := loadPem(c.ClientCert)
-		if err != nil {
-			return fmt.Errorf("failed to load client certificate (%w)", err)
-		}
-		clientKey, err := loadPem(c.ClientKey)
+	if c.ClientPKCS12 != "" && c.ClientCert != "" {
+		return fmt.Errorf("client PKCS #12 file provided together with a client certificate")
+	}
+	if err := c.KeyPassphrase.Validate(); err != nil {
+		return fmt.Errorf("invalid key passphrase configuration (%w)", err)
+	}
+
+	if c.ClientCert != "" && c.ClientKey != "" || c.ClientPKCS12 != "" {
+		cert, err := loadCertificate(c.ClientCert, c.ClientKey, c.ClientPKCS12, c.KeyPassphrase)
 		if err != nil {
 			return fmt.Errorf("failed to load client certificate (%w)", err)
 		}
-		cert, err := tls.X509KeyPair(clientCert, clientKey)
-		if err != nil {
-			return fmt.Errorf("failed to load certificate or key (%w)", err)
-		}
-		c.cert = &cert
+		c.cert = cert
 	}
 	return nil
 }
@@ -403,36 +754,362 @@ func (c *ClientConfiguration) validateCACert() (err error) {
 	return nil
 }
 
+// RedirectPolicy restricts which HTTP redirects the client follows.
+//goland:noinspection GoVetStructTag
+type RedirectPolicy struct {
+	// MaxRedirects is the maximum number of redirects to follow for a single request. Zero means 10.
+	MaxRedirects int `json:"maxRedirects" yaml:"maxRedirects" comment:"Maximum number of redirects to follow." default:"10"`
+
+	// SameHost only allows redirects to the same host and port as the original request.
+	SameHost bool `json:"sameHost" yaml:"sameHost" comment:"Only follow redirects to the same host and port."`
+
+	// SameScheme only allows redirects using the same scheme as the original request.
+	SameScheme bool `json:"sameScheme" yaml:"sameScheme" comment:"Only follow redirects using the same scheme."`
+
+	// AllowedHosts is a list of host names redirects are allowed to. Entries starting with *. match all subdomains.
+	// If empty, all hosts are allowed.
+	AllowedHosts []string `json:"allowedHosts" yaml:"allowedHosts" comment:"Host names redirects are allowed to. *.example.com matches all subdomains."`
+
+	// AllowDowngrade allows redirects from https:// to http:// URLs. Defaults to false.
+	AllowDowngrade bool `json:"allowDowngrade" yaml:"allowDowngrade" comment:"Allow redirects from https:// to http:// URLs."`
+
+	// SensitiveHeaders are removed from the request when it is redirected to a different scheme, host or port. If
+	// empty, Authorization, Proxy-Authorization and Cookie are removed.
+	SensitiveHeaders []string `json:"sensitiveHeaders" yaml:"sensitiveHeaders" comment:"Headers to remove on redirects to a different scheme, host, or port." default:"[\"Authorization\",\"Proxy-Authorization\",\"Cookie\"]"`
+}
+
+// Validate validates the redirect policy.
+func (r RedirectPolicy) Validate() error {
+	if r.MaxRedirects < 0 {
+		return fmt.Errorf("the maximum number of redirects cannot be negative")
+	}
+	for _, host := range r.AllowedHosts {
+		if strings.TrimSpace(host) == "" {
+			return fmt.Errorf("empty host in the list of allowed hosts")
+		}
+	}
+	return nil
+}
+
+func (r RedirectPolicy) getSensitiveHeaders() []string {
+	if len(r.SensitiveHeaders) == 0 {
+		return []string{"Authorization", "Proxy-Authorization", "Cookie"}
+	}
+	return r.SensitiveHeaders
+}
+
+// check returns an error if the redirect to req is not allowed by the policy.
+func (r RedirectPolicy) check(req *http.Request, via []*http.Request) error {
+	maxRedirects := r.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	original := via[0].URL
+	previous := via[len(via)-1].URL
+	if r.SameHost && !strings.EqualFold(req.URL.Host, original.Host) {
+		return fmt.Errorf("redirect to a different host")
+	}
+	if r.SameScheme && !strings.EqualFold(req.URL.Scheme, original.Scheme) {
+		return fmt.Errorf("redirect to a different scheme")
+	}
+	if !r.AllowDowngrade && strings.EqualFold(previous.Scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https") {
+		return fmt.Errorf("redirect from https to %s", req.URL.Scheme)
+	}
+	if len(r.AllowedHosts) > 0 {
+		allowed := false
+		for _, pattern := range r.AllowedHosts {
+			if hostMatches(req.URL.Hostname(), pattern) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("host %s is not in the list of allowed hosts", req.URL.Hostname())
+		}
+	}
+	return nil
+}
+
+// DestinationPolicy restricts which IP addresses the client may connect to, protecting against server-side request
+// forgery when URLs come from untrusted sources. The policy is checked against the resolved IP address of every
+// connection, including connections made for redirects. Loopback, link-local and IPv6 unique local addresses, such
+// as cloud metadata endpoints, are denied by default. IPv6 addresses embedding an IPv4 address, such as
+// ::ffff:169.254.169.254, are also checked as that IPv4 address.
+//goland:noinspection GoVetStructTag
+type DestinationPolicy struct {
+	// Allow is a list of CIDRs or IP addresses the client may connect to. If not empty, all other addresses are
+	// denied. Addresses on this list are allowed even if they are loopback or link-local addresses.
+	Allow []string `json:"allow" yaml:"allow" comment:"CIDRs the client may connect to. If set, all other addresses are denied."`
+
+	// Deny is a list of CIDRs or IP addresses the client may not connect to. It takes precedence over Allow.
+	Deny []string `json:"deny" yaml:"deny" comment:"CIDRs the client may not connect to. Takes precedence over the allow list."`
+
+	// AllowLoopback allows connecting to loopback addresses, such as 127.0.0.1 or ::1. Defaults to false.
+	AllowLoopback bool `json:"allowLoopback" yaml:"allowLoopback" comment:"Allow connecting to loopback addresses."`
+
+	// AllowLinkLocal allows connecting to link-local addresses, such as 169.254.169.254. Defaults to false.
+	AllowLinkLocal bool `json:"allowLinkLocal" yaml:"allowLinkLocal" comment:"Allow connecting to link-local addresses."`
+
+	// AllowUniqueLocal allows connecting to IPv6 unique local addresses (fc00::/7), such as fd00:ec2::254. Defaults to
+	// false.
+	AllowUniqueLocal bool `json:"allowUniqueLocal" yaml:"allowUniqueLocal" comment:"Allow connecting to IPv6 unique local addresses."`
+}
+
+// Validate validates the destination policy.
+func (d DestinationPolicy) Validate() error {
+	_, err := newDestinationChecker(d)
+	return err
+}
+
+// CookieJarConfiguration configures the cookie jar of the client. Each client has its own jar, which matches domains
+// using the public suffix list.
+//goland:noinspection GoVetStructTag
+type CookieJarConfiguration struct {
+	// Enabled turns on storing cookies received from the server and sending them with subsequent requests.
+	Enabled bool `json:"enabled" yaml:"enabled" comment:"Store cookies and send them with subsequent requests."`
+
+	// File is the path to a file to persist the cookies to, so they survive restarts. If empty, cookies are only
+	// kept in memory.
+	File string `json:"file" yaml:"file" comment:"File to persist cookies to. If empty, cookies are kept in memory only."`
+}
+
+// Validate validates the cookie jar configuration.
+func (c CookieJarConfiguration) Validate() error {
+	if c.File != "" && !c.Enabled {
+		return fmt.Errorf("cookie jar file provided, but the cookie jar is not enabled")
+	}
+	return nil
+}
+
+// DebugConfiguration configures logging the headers and bodies of requests and responses. Sensitive headers and
+// fields are redacted before logging. Only JSON and form-encoded bodies can be redacted, other bodies are logged as
+// their size and content type.
+//goland:noinspection GoVetStructTag
+type DebugConfiguration struct {
+	// Enabled turns on logging headers and bodies at the debug level. Defaults to false.
+	Enabled bool `json:"enabled" yaml:"enabled" comment:"Log request and response headers and bodies at the debug level."`
+
+	// MaxBodyLength is the number of bytes of the body to log after redaction. Longer bodies are truncated. Zero
+	// means 4096.
+	MaxBodyLength int `json:"maxBodyLength" yaml:"maxBodyLength" comment:"Maximum number of body bytes to log." default:"4096"`
+
+	// RedactHeaders is the list of headers whose values are not logged. Matching is case-insensitive. If empty,
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie are redacted.
+	RedactHeaders []string `json:"redactHeaders" yaml:"redactHeaders" comment:"Headers whose values are not logged." default:"[\"Authorization\",\"Proxy-Authorization\",\"Cookie\",\"Set-Cookie\"]"`
+
+	// RedactFields is the list of body fields and query parameters whose values are not logged. Names containing a
+	// dot, such as "user.password", match the full path from the document root, other names match the field at any
+	// depth. Matching is case-insensitive. If empty, password, authorization, privateKey, token and secret are redacted.
+	RedactFields []string `json:"redactFields" yaml:"redactFields" comment:"Body fields whose values are not logged." default:"[\"password\",\"authorization\",\"privateKey\",\"token\",\"secret\"]"`
+}
+
+// Validate validates the debug configuration.
+func (d DebugConfiguration) Validate() error {
+	if d.MaxBodyLength < 0 {
+		return fmt.Errorf("the maximum body length cannot be negative")
+	}
+	for _, header := range d.RedactHeaders {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("empty header name in the redacted headers")
+		}
+	}
+	for _, field := range d.RedactFields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("empty field name in the redacted fields")
+		}
+	}
+	return nil
+}
+
+// FixtureMode selects if requests are recorded to or replayed from the fixture file.
+type FixtureMode string
+
+const (
+	// FixtureModeDisabled sends requests to the server without recording them.
+	FixtureModeDisabled FixtureMode = ""
+	// FixtureModeRecord sends requests to the server and records the requests and responses to the fixture file.
+	FixtureModeRecord FixtureMode = "record"
+	// FixtureModeReplay serves responses from the fixture file without sending requests to the server.
+	FixtureModeReplay FixtureMode = "replay"
+)
+
+// Validate validates the fixture mode.
+func (f FixtureMode) Validate() error {
+	switch f {
+	case FixtureModeDisabled:
+	case FixtureModeRecord:
+	case FixtureModeReplay:
+	default:
+		return fmt.Errorf("invalid fixture mode: %s", f)
+	}
+	return nil
+}
+
+// FixtureMatchField is a part of the request that is compared when looking for a recorded response.
+type FixtureMatchField string
+
+const (
+	// FixtureMatchMethod matches the HTTP method.
+	FixtureMatchMethod FixtureMatchField = "method"
+	// FixtureMatchPath matches the path including the query string.
+	FixtureMatchPath FixtureMatchField = "path"
+	// FixtureMatchBody matches the encoded request body. Fields redacted in debug logs are not compared, and bodies
+	// other than JSON and form data are compared by their SHA-256 digest.
+	FixtureMatchBody FixtureMatchField = "body"
+)
+
+// Validate validates the match field.
+func (f FixtureMatchField) Validate() error {
+	switch f {
+	case FixtureMatchMethod:
+	case FixtureMatchPath:
+	case FixtureMatchBody:
+	default:
+		return fmt.Errorf("invalid fixture match field: %s", f)
+	}
+	return nil
+}
+
+// FixtureConfiguration configures recording and replaying requests for tests.
+//goland:noinspection GoVetStructTag
+type FixtureConfiguration struct {
+	// Mode is either "record" or "replay". If empty, requests are sent to the server without recording.
+	Mode FixtureMode `json:"mode" yaml:"mode" comment:"Fixture mode: record or replay. Empty disables fixtures."`
+
+	// File is the fixture file to record to or replay from. Recording overwrites the file.
+	File string `json:"file" yaml:"file" comment:"Fixture file to record to or replay from."`
+
+	// MatchOn is the list of request parts that must match a recorded request in replay mode. If empty, the method
+	// and the path are matched.
+	MatchOn []FixtureMatchField `json:"matchOn" yaml:"matchOn" comment:"Request parts to match: method, path, body." default:"[\"method\",\"path\"]"`
+
+	// MatchHeaders is a list of request headers that must match a recorded request in replay mode. Only these
+	// headers are recorded, so credentials are not written to the fixture file. Headers redacted in debug logs are
+	// recorded without their value and only their presence is matched.
+	MatchHeaders []string `json:"matchHeaders" yaml:"matchHeaders" comment:"Request headers to record and match."`
+}
+
+// Validate validates the fixture configuration.
+func (f FixtureConfiguration) Validate() error {
+	if err := f.Mode.Validate(); err != nil {
+		return err
+	}
+	if f.Mode != FixtureModeDisabled && f.File == "" {
+		return fmt.Errorf("no fixture file provided")
+	}
+	for _, field := range f.MatchOn {
+		if err := field.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, header := range f.MatchHeaders {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("empty header name in the matched headers")
+		}
+	}
+	return nil
+}
+
+// HedgingConfiguration configures hedged requests. When enabled, idempotent requests that have not received a
+// response within Delay are sent a second time, and the first good response is used.
+//goland:noinspection GoVetStructTag
+type HedgingConfiguration struct {
+	// Enabled turns on hedged requests for idempotent HTTP methods. Defaults to false.
+	Enabled bool `json:"enabled" yaml:"enabled" comment:"Send a second request for idempotent methods if the first one is slow."`
+
+	// Delay is the time to wait for a response before sending the hedged request.
+	Delay time.Duration `json:"delay" yaml:"delay" comment:"Time to wait for a response before sending the hedged request." default:"200ms"`
+
+	// URLs is a list of alternative base URLs to send hedged requests to. If more than one is given they are used in
+	// turn. If empty, the hedged request is sent to the main URL.
+	URLs []string `json:"urls" yaml:"urls" comment:"Alternative base URLs to send hedged requests to."`
+}
+
+// Validate validates the hedging configuration against the base URL and timeout of the client.
+func (h HedgingConfiguration) Validate(baseURL string, timeout time.Duration) error {
+	if !h.Enabled {
+		return nil
+	}
+	if h.Delay <= 0 {
+		return fmt.Errorf("hedging delay must be positive")
+	}
+	if h.Delay >= timeout {
+		return fmt.Errorf("hedging delay %s must be lower than the timeout %s", h.Delay.String(), timeout.String())
+	}
+	for _, hedgeURL := range h.URLs {
+		if _, err := url.ParseRequestURI(hedgeURL); err != nil {
+			return fmt.Errorf("invalid hedging URL: %s", hedgeURL)
+		}
+		if strings.HasPrefix(hedgeURL, "https://") != strings.HasPrefix(baseURL, "https://") {
+			return fmt.Errorf("hedging URL %s must use the same scheme as %s", hedgeURL, baseURL)
+		}
+	}
+	return nil
+}
+
 // ServerConfiguration is a structure to configure the simple HTTP server by.
 //goland:noinspection GoVetStructTag
 type ServerConfiguration struct {
 	// Listen contains the IP and port to listen on.
 	Listen string `json:"listen" yaml:"listen" default:"0.0.0.0:8080"`
-	// Key contains either a file name to a private key, or the private key itself in PEM format to use as a server key.
+	// Key contains either a file name to a private key, a secret reference, or the private key itself in PEM format
+	// to use as a server key.
 	Key string `json:"key" yaml:"key"`
-	// Cert contains either a file to a certificate, or the certificate itself in PEM format to use as a server
-	// certificate.
+	// Cert contains either a file to a certificate, a secret reference, or the certificate itself in PEM format to use
+	// as a server certificate.
 	Cert string `json:"cert" yaml:"cert"`
-	// ClientCACert contains either a file or a certificate in PEM format to verify the connecting clients by.
+	// PKCS12 contains the name of a PKCS #12 (PFX) file or a secret reference with the server certificate, its chain
+	// and the private key. It can be used instead of Cert and Key.
+	PKCS12 string `json:"pkcs12" yaml:"pkcs12"`
+	// KeyPassphrase is the passphrase of an encrypted PKCS #8 Key or of the PKCS12 file.
+	KeyPassphrase PassphraseConfiguration `json:"keyPassphrase" yaml:"keyPassphrase"`
+	// ClientCACert contains either a file, a secret reference, or a certificate in PEM format to verify the connecting
+	// clients by.
 	ClientCACert string `json:"clientcacert" yaml:"clientcacert"`
 
-	// TLSVersion is the minimum TLS version to use.
-	TLSVersion TLSVersion `json:"tlsVersion" yaml:"tlsVersion" default:"1.3"`
+	// Revocation configures checking client certificates against CRLs and an OCSP responder. It requires
+	// ClientCACert.
+	Revocation RevocationConfiguration `json:"revocation" yaml:"revocation"`
+
+	// HTTPVersion is the HTTP protocol version to offer. "2" enables HTTP/2 on TLS connections in addition to
+	// HTTP/1.1, "h2c" enables unencrypted HTTP/2 with prior knowledge in addition to HTTP/1.1. If empty, HTTP/2 is
+	// offered on TLS connections if the cipher suites allow it.
+	HTTPVersion HTTPVersion `json:"httpVersion" yaml:"httpVersion"`
 
-	// ECDHCurves is the list of curve algorithms to support.
-	ECDHCurves ECDHCurveList `json:"curves" yaml:"curves" default:"[\"x25519\",\"secp256r1\",\"secp384r1\",\"secp521r1\"]"`
+	// TLSProfile selects the TLS versions, cipher suites and curves to use. TLSVersion, ECDHCurves and
+	// CipherSuites override the profile if set. Defaults to modern.
+	TLSProfile TLSProfile `json:"tlsProfile" yaml:"tlsProfile" default:"modern"`
 
-	// CipherSuites is a list of supported cipher suites.
-	CipherSuites CipherSuiteList `json:"cipher" yaml:"cipher" default:"[\"TLS_AES_128_GCM_SHA256\",\"TLS_AES_256_GCM_SHA384\",\"TLS_CHACHA20_POLY1305_SHA256\",\"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256\",\"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256\"]"`
+	// TLSVersion is the minimum TLS version to use. Empty means the minimum version of the TLS profile.
+	TLSVersion TLSVersion `json:"tlsVersion" yaml:"tlsVersion"`
+
+	// MaxTLSVersion is the maximum TLS version to use. Empty means the newest version supported.
+	MaxTLSVersion TLSVersion `json:"maxTLSVersion" yaml:"maxTLSVersion"`
+
+	// AllowInsecureTLSVersions allows setting TLSVersion to the insecure TLS versions 1.0 and 1.1.
+	AllowInsecureTLSVersions bool `json:"allowInsecureTLSVersions" yaml:"allowInsecureTLSVersions"`
+
+	// ECDHCurves is the list of curve algorithms to support. Empty means the curves of the TLS profile.
+	ECDHCurves ECDHCurveList `json:"curves" yaml:"curves"`
+
+	// CipherSuites is a list of supported cipher suites. Empty means the cipher suites of the TLS profile.
+	CipherSuites CipherSuiteList `json:"cipher" yaml:"cipher"`
 
 	// cert is for internal use only. It contains the key and certificate after Validate.
 	cert *tls.Certificate `json:"-" yaml:"-"`
 	// clientCAPool is for internal use only. It contains the client CA pool after Validate.
 	clientCAPool *x509.CertPool `json:"-" yaml:"-"`
+	// tlsWarnings is for internal use only. It contains the TLS configuration problems found by Validate.
+	tlsWarnings []string `json:"-" yaml:"-"`
 }
 
-// Validate validates the server configuration.
+// Validate validates the server configuration. Combinations of TLS settings and the certificate that are valid but
+// keep some or all connections from being negotiated are returned by TLSWarnings afterwards.
 func (config *ServerConfiguration) Validate() error {
+	config.tlsWarnings = nil
 	if config.Listen == "" {
 		return fmt.Errorf("no listen address provided")
 	}
@@ -445,31 +1122,59 @@ func (config *ServerConfiguration) Validate() error {
 	if config.Cert == "" && config.Key != "" {
 		return fmt.Errorf("key provided without certificate")
 	}
+	if config.PKCS12 != "" && config.Cert != "" {
+		return fmt.Errorf("PKCS #12 file provided together with a certificate")
+	}
+	if err := config.HTTPVersion.Validate(); err != nil {
+		return err
+	}
+	if config.HTTPVersion == HTTPVersionH2C && (config.Cert != "" || config.PKCS12 != "") {
+		return fmt.Errorf("h2c cannot be used with TLS, use HTTP version 2 for HTTP/2 over TLS")
+	}
+	if err := config.KeyPassphrase.Validate(); err != nil {
+		return fmt.Errorf("invalid key passphrase configuration (%w)", err)
+	}
 
-	if config.Cert != "" && config.Key != "" {
-		pemCert, err := loadPem(config.Cert)
-		if err != nil {
-			return fmt.Errorf("failed to load certificate (%w)", err)
-		}
-		pemKey, err := loadPem(config.Key)
-		if err != nil {
-			return fmt.Errorf("failed to load key (%w)", err)
-		}
-		cert, err := tls.X509KeyPair(pemCert, pemKey)
+	if config.Cert != "" && config.Key != "" || config.PKCS12 != "" {
+		cert, err := loadCertificate(config.Cert, config.Key, config.PKCS12, config.KeyPassphrase)
 		if err != nil {
 			return fmt.Errorf("failed to load key/certificate (%w)", err)
 		}
-		config.cert = &cert
+		config.cert = cert
 
-		if err := config.TLSVersion.Validate(); err != nil {
+		if err := config.TLSProfile.Validate(); err != nil {
+			return err
+		}
+		settings := config.getTLSSettings()
+		if err := validateTLSVersions(
+			settings.minVersion,
+			config.MaxTLSVersion,
+			config.AllowInsecureTLSVersions,
+		); err != nil {
 			return fmt.Errorf("invalid TLS version (%w)", err)
 		}
-		if err := config.ECDHCurves.Validate(); err != nil {
+		if err := settings.curves.Validate(); err != nil {
 			return fmt.Errorf("invalid curve algorithms (%w)", err)
 		}
-		if err := config.CipherSuites.Validate(); err != nil {
+		if err := settings.cipherSuites.Validate(); err != nil {
 			return fmt.Errorf("invalid cipher suites (%w)", err)
 		}
+		if config.HTTPVersion == HTTPVersion2 && !settings.supportsHTTP2() {
+			return fmt.Errorf(
+				"HTTP/2 requires the TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or " +
+					"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 cipher suite when TLS 1.2 or older is allowed, " +
+					"add one of them or set the HTTP version to 1.1",
+			)
+		}
+		config.tlsWarnings = settings.warnings(config.cert)
+		if config.HTTPVersion == HTTPVersionDefault && !settings.supportsHTTP2() {
+			config.tlsWarnings = append(
+				config.tlsWarnings,
+				"HTTP/2 is disabled because the cipher suites contain neither "+
+					"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 nor TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, "+
+					"which HTTP/2 requires when TLS 1.2 or older is allowed",
+			)
+		}
 	}
 
 	if config.ClientCACert != "" {
@@ -485,10 +1190,34 @@ func (config *ServerConfiguration) Validate() error {
 		config.clientCAPool = caCertPool
 	}
 
+	if err := config.Revocation.Validate(); err != nil {
+		return fmt.Errorf("invalid revocation configuration (%w)", err)
+	}
+	if config.Revocation.enabled() && config.ClientCACert == "" {
+		return fmt.Errorf("revocation checking requires a client CA certificate")
+	}
+
 	return nil
 }
 
-// RequestEncoding is the method by which the response is encoded.
+// TLSWarnings returns the TLS configuration problems found by the last call to Validate, such as a certificate key
+// type none of the configured cipher suites supports.
+func (config *ServerConfiguration) TLSWarnings() []string {
+	return config.tlsWarnings
+}
+
+// getTLSSettings returns the TLS settings of the profile, overridden by the explicitly configured ones.
+func (config *ServerConfiguration) getTLSSettings() tlsSettings {
+	return config.TLSProfile.getSettings(
+		config.TLSVersion,
+		config.MaxTLSVersion,
+		config.CipherSuites,
+		config.ECDHCurves,
+	)
+}
+
+// RequestEncoding is the method by which the request body is encoded. Custom encodings can be added using
+// RegisterRequestEncoder.
 type RequestEncoding string
 
 // RequestEncodingJSON is the default encoding and encodes the body to JSON.
@@ -500,16 +1229,299 @@ const RequestEncodingJSON = "JSON"
 // RequestEncodingWWURLEncoded encodes the body via www-urlencoded.
 const RequestEncodingWWWURLEncoded = "WWW-URLENCODED"
 
+// RequestEncodingMultipart encodes the body as multipart/form-data.
+const RequestEncodingMultipart = "MULTIPART-FORM-DATA"
+
+// RequestEncodingXML encodes the body to XML.
+const RequestEncodingXML = "XML"
+
+// RequestEncodingYAML encodes the body to YAML.
+const RequestEncodingYAML = "YAML"
+
+// RequestEncodingMessagePack encodes the body to MessagePack.
+const RequestEncodingMessagePack = "MSGPACK"
+
+// RequestEncodingCBOR encodes the body to CBOR.
+const RequestEncodingCBOR = "CBOR"
+
 // Validate validates the RequestEncoding
 func (r RequestEncoding) Validate() error {
+	if _, ok := getRequestEncoder(r); !ok {
+		return fmt.Errorf("invalid request encoding: %s", r)
+	}
+	return nil
+}
+
+// PassphraseConfiguration configures the passphrase of an encrypted key. At most one of the fields can be set.
+//goland:noinspection GoVetStructTag
+type PassphraseConfiguration struct {
+	// Value is the passphrase itself. It is never interpreted as a secret reference.
+	Value string `json:"value" yaml:"value" comment:"Passphrase of the key."`
+
+	// Ref is a secret reference to the passphrase, for example env:VAR or file:/path. A trailing newline is removed.
+	Ref string `json:"ref" yaml:"ref" comment:"Secret reference to the passphrase of the key, for example env:VAR or file:/path."`
+
+	// File is the name of a file containing the passphrase. A trailing newline is removed.
+	//
+	// Deprecated: use Ref with a file: reference instead.
+	File string `json:"file" yaml:"file" comment:"Deprecated: file containing the passphrase of the key. Use ref instead."`
+
+	// Env is the name of an environment variable containing the passphrase.
+	//
+	// Deprecated: use Ref with an env: reference instead.
+	Env string `json:"env" yaml:"env" comment:"Deprecated: environment variable containing the passphrase of the key. Use ref instead."`
+}
+
+// Validate validates the passphrase configuration.
+func (p PassphraseConfiguration) Validate() error {
+	set := 0
+	for _, value := range []string{p.Value, p.Ref, p.File, p.Env} {
+		if value != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("only one of the passphrase value, reference, file and environment variable can be set")
+	}
+	return nil
+}
+
+// ref returns the secret reference to the passphrase, mapping the deprecated File and Env fields to file: and env:
+// references.
+func (p PassphraseConfiguration) ref() string {
+	switch {
+	case p.File != "":
+		return "file:" + p.File
+	case p.Env != "":
+		return "env:" + p.Env
+	default:
+		return p.Ref
+	}
+}
+
+// load returns the passphrase, or nil if none is configured.
+func (p PassphraseConfiguration) load() ([]byte, error) {
+	switch {
+	case p.Value != "":
+		return []byte(p.Value), nil
+	case p.ref() != "":
+		ref := p.ref()
+		value, ok, err := resolveSecretReference(ref)
+		if !ok {
+			return nil, fmt.Errorf("the passphrase reference has no registered secret provider: %s", ref)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(value, "\r\n"), nil
+	default:
+		return nil, nil
+	}
+}
+
+// RevocationMode decides what happens if the revocation status of a certificate cannot be determined.
+type RevocationMode string
+
+const (
+	// RevocationModeHardFail rejects the connection if the revocation status cannot be determined. This is the
+	// default.
+	RevocationModeHardFail RevocationMode = "hard"
+	// RevocationModeSoftFail allows the connection with a warning if the revocation status cannot be determined.
+	// Revoked certificates are still rejected.
+	RevocationModeSoftFail RevocationMode = "soft"
+)
+
+// Validate validates the revocation mode.
+func (r RevocationMode) Validate() error {
 	switch r {
-	case RequestEncodingDefault:
-		return nil
-	case RequestEncodingJSON:
-		return nil
-	case RequestEncodingWWWURLEncoded:
-		return nil
+	case "":
+	case RevocationModeHardFail:
+	case RevocationModeSoftFail:
 	default:
-		return fmt.Errorf("invalid request encoding: %s", r)
+		return fmt.Errorf("invalid revocation mode: %s", r)
+	}
+	return nil
+}
+
+// RevocationConfiguration configures checking peer certificates for revocation. Each certificate in the verified
+// chain is checked against the CRL of its issuer, and the peer certificate is also checked with the OCSP responder.
+//goland:noinspection GoVetStructTag
+type RevocationConfiguration struct {
+	// CRLFiles is a list of files containing certificate revocation lists in PEM or DER format. Certificates whose
+	// issuer has no CRL in these files are not checked against a CRL.
+	CRLFiles []string `json:"crlFiles" yaml:"crlFiles" comment:"Files containing CRLs in PEM or DER format."`
+
+	// CRLRefreshInterval is the time after which the CRL files are read again. Zero means one hour.
+	CRLRefreshInterval time.Duration `json:"crlRefreshInterval" yaml:"crlRefreshInterval" comment:"Time after which the CRL files are read again." default:"1h"`
+
+	// OCSPResponder is the URL of the OCSP responder to query for the status of the peer certificate. If empty, OCSP
+	// is not used.
+	OCSPResponder string `json:"ocspResponder" yaml:"ocspResponder" comment:"URL of the OCSP responder."`
+
+	// OCSPTimeout is the time to wait for the OCSP responder. Zero means five seconds.
+	OCSPTimeout time.Duration `json:"ocspTimeout" yaml:"ocspTimeout" comment:"Time to wait for the OCSP responder." default:"5s"`
+
+	// Mode is "hard" to reject connections if the revocation status cannot be determined, or "soft" to allow them
+	// with a warning. Defaults to hard.
+	Mode RevocationMode `json:"mode" yaml:"mode" comment:"hard or soft: what to do if the revocation status cannot be determined." default:"hard"`
+}
+
+// Validate validates the revocation configuration and checks that the CRL files can be loaded.
+func (r RevocationConfiguration) Validate() error {
+	if err := r.Mode.Validate(); err != nil {
+		return err
+	}
+	if r.CRLRefreshInterval < 0 || r.OCSPTimeout < 0 {
+		return fmt.Errorf("the CRL refresh interval and OCSP timeout cannot be negative")
+	}
+	for _, file := range r.CRLFiles {
+		if _, err := loadCRLFile(file); err != nil {
+			return err
+		}
+	}
+	if r.OCSPResponder != "" {
+		u, err := url.Parse(r.OCSPResponder)
+		if err != nil {
+			return fmt.Errorf("invalid OCSP responder URL (%w)", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid OCSP responder URL: %s", r.OCSPResponder)
+		}
+	}
+	return nil
+}
+
+func (r RevocationConfiguration) enabled() bool {
+	return len(r.CRLFiles) > 0 || r.OCSPResponder != ""
+}
+
+func (r RevocationConfiguration) getCRLRefreshInterval() time.Duration {
+	if r.CRLRefreshInterval == 0 {
+		return defaultCRLRefreshInterval
+	}
+	return r.CRLRefreshInterval
+}
+
+func (r RevocationConfiguration) getOCSPTimeout() time.Duration {
+	if r.OCSPTimeout == 0 {
+		return defaultOCSPTimeout
+	}
+	return r.OCSPTimeout
+}
+
+// HealthCheckConfiguration configures the probe request of the client health check.
+//goland:noinspection GoVetStructTag
+type HealthCheckConfiguration struct {
+	// Method is the HTTP method of the probe request. Defaults to GET.
+	Method string `json:"method" yaml:"method" comment:"HTTP method of the probe request." default:"GET"`
+
+	// Path is the path of the probe request relative to the URL.
+	Path string `json:"path" yaml:"path" comment:"Path of the probe request."`
+
+	// ExpectedStatus is the list of status codes that indicate a healthy server. If empty, any 2xx status code is
+	// accepted.
+	ExpectedStatus []int `json:"expectedStatus" yaml:"expectedStatus" comment:"Status codes of a healthy server. Defaults to any 2xx status code."`
+}
+
+// Validate validates the health check configuration.
+func (h HealthCheckConfiguration) Validate() error {
+	if h.Method != "" && strings.ToUpper(h.Method) != h.Method {
+		return fmt.Errorf("invalid HTTP method: %s", h.Method)
+	}
+	for _, status := range h.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid expected status code: %d", status)
+		}
+	}
+	return nil
+}
+
+func (h HealthCheckConfiguration) getMethod() string {
+	if h.Method == "" {
+		return http.MethodGet
+	}
+	return h.Method
+}
+
+func (h HealthCheckConfiguration) isExpectedStatus(statusCode int) bool {
+	if len(h.ExpectedStatus) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, expected := range h.ExpectedStatus {
+		if expected == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueConfiguration is the configuration of the durable delivery queue.
+//goland:noinspection GoVetStructTag
+type QueueConfiguration struct {
+	// Directory is the directory pending requests are persisted to. Dead-lettered requests are moved to the dead
+	// subdirectory.
+	Directory string `json:"directory" yaml:"directory" comment:"Directory to persist pending requests to."`
+
+	// MaxAttempts is the number of delivery attempts before a request is dead-lettered. Zero means 10.
+	MaxAttempts int `json:"maxAttempts" yaml:"maxAttempts" comment:"Delivery attempts before a request is dead-lettered." default:"10"`
+
+	// InitialBackoff is the time to wait before the first retry. It is doubled for each further retry. Zero means
+	// one second.
+	InitialBackoff time.Duration `json:"initialBackoff" yaml:"initialBackoff" comment:"Time to wait before the first retry." default:"1s"`
+
+	// MaxBackoff is the longest time to wait between retries. Zero means five minutes.
+	MaxBackoff time.Duration `json:"maxBackoff" yaml:"maxBackoff" comment:"Longest time to wait between retries." default:"5m"`
+}
+
+// Validate validates the queue configuration.
+func (q QueueConfiguration) Validate() error {
+	if q.Directory == "" {
+		return fmt.Errorf("no queue directory provided")
+	}
+	if q.MaxAttempts < 0 {
+		return fmt.Errorf("the maximum number of attempts cannot be negative")
+	}
+	if q.InitialBackoff < 0 || q.MaxBackoff < 0 {
+		return fmt.Errorf("the backoff cannot be negative")
+	}
+	if q.getMaxBackoff() < q.getInitialBackoff() {
+		return fmt.Errorf("the maximum backoff (%s) is less than the initial backoff (%s)", q.getMaxBackoff(), q.getInitialBackoff())
+	}
+	return nil
+}
+
+func (q QueueConfiguration) getMaxAttempts() int {
+	if q.MaxAttempts == 0 {
+		return defaultQueueMaxAttempts
+	}
+	return q.MaxAttempts
+}
+
+func (q QueueConfiguration) getInitialBackoff() time.Duration {
+	if q.InitialBackoff == 0 {
+		return defaultQueueInitialBackoff
+	}
+	return q.InitialBackoff
+}
+
+func (q QueueConfiguration) getMaxBackoff() time.Duration {
+	if q.MaxBackoff == 0 {
+		return defaultQueueMaxBackoff
+	}
+	return q.MaxBackoff
+}
+
+// backoff returns the time to wait after the given number of failed attempts.
+func (q QueueConfiguration) backoff(attempts int) time.Duration {
+	backoff := q.getInitialBackoff()
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= q.getMaxBackoff() {
+			return q.getMaxBackoff()
+		}
+	}
+	if backoff > q.getMaxBackoff() {
+		return q.getMaxBackoff()
 	}
+	return backoff
 }
@@ -0,0 +1,23 @@
+//go:build go1.26
+
+package http
+
+import (
+	"crypto/tls"
+)
+
+// Hybrid post-quantum key exchange algorithms combining a NIST curve with ML-KEM. They require Go 1.26 or newer and
+// are only negotiated with TLS 1.3.
+const (
+	ECDHCurveSecP256r1MLKEM768     ECDHCurve = "secp256r1mlkem768"
+	ECDHCurveSecP256r1MLKEM768Alt  ECDHCurve = "SecP256r1MLKEM768"
+	ECDHCurveSecP384r1MLKEM1024    ECDHCurve = "secp384r1mlkem1024"
+	ECDHCurveSecP384r1MLKEM1024Alt ECDHCurve = "SecP384r1MLKEM1024"
+)
+
+func init() {
+	curveToID[ECDHCurveSecP256r1MLKEM768] = tls.SecP256r1MLKEM768
+	curveToID[ECDHCurveSecP256r1MLKEM768Alt] = tls.SecP256r1MLKEM768
+	curveToID[ECDHCurveSecP384r1MLKEM1024] = tls.SecP384r1MLKEM1024
+	curveToID[ECDHCurveSecP384r1MLKEM1024Alt] = tls.SecP384r1MLKEM1024
+}
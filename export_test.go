@@ -0,0 +1,9 @@
+package http
+
+// UnregisterSecretProvider removes a secret provider registered by a test, so the tests can run more than once in
+// the same process.
+func UnregisterSecretProvider(scheme string) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+	delete(secretProviders, scheme)
+}
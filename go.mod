@@ -1,13 +1,30 @@
 module github.com/containerssh/http
 
-go 1.16
+go 1.24.0
 
 require (
 	github.com/containerssh/log v1.1.6
 	github.com/containerssh/service v1.0.0
 	github.com/containerssh/structutils v1.1.0
+	github.com/fxamacker/cbor/v2 v2.9.4
 	github.com/gorilla/schema v1.2.0
 	github.com/stretchr/testify v1.7.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/crypto v0.48.0
+	golang.org/x/net v0.50.0
+	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require (
+	github.com/creasty/defaults v1.5.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/imdario/mergo v0.3.12 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/qdm12/reprint v0.0.0-20200326205758-722754a53494 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 )
 
 // Fixes CVE-2019-11254
@@ -80,8 +80,8 @@ func (h *handler) ServeHTTP(goWriter goHttp.ResponseWriter, goRequest *goHttp.Re
 			panic(fmt.Errorf("bug: failed to marshal internal server error JSON response (%w)", err))
 		}
 	}
+	goWriter.Header().Set("Content-Type", responseType)
 	goWriter.WriteHeader(int(response.statusCode))
-	goWriter.Header().Add("Content-Type", responseType)
 	if _, err := goWriter.Write(bytes); err != nil {
 		h.logger.Debug(log.Wrap(err, MServerResponseWriteFailed, "Failed to write HTTP response"))
 	}
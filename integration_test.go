@@ -1,16 +1,14 @@
 package http_test
 
 import (
-	"bytes"
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
-	"net"
+	"io/ioutil"
+	goHttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -20,6 +18,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+	"github.com/containerssh/http/mock"
 )
 
 type Request struct {
@@ -75,6 +75,7 @@ func createClientServerConfig() (http.ClientConfiguration, http.ServerConfigurat
 	structutils.Defaults(&clientConfig)
 	structutils.Defaults(&serverConfig)
 	clientConfig.URL = "http://127.0.0.1:8080/"
+	clientConfig.DestinationPolicy.AllowLoopback = true
 	serverConfig.Listen = "127.0.0.1:8080"
 	return clientConfig, serverConfig
 }
@@ -96,16 +97,12 @@ func TestUnencryptedFailure(t *testing.T) {
 }
 
 func TestEncrypted(t *testing.T) {
-	caPrivKey, caCert, caCertBytes, err := createCA()
+	ca, err := certs.NewCA(certs.WithKeyType(certs.KeyTypeRSA))
 	if err != nil {
 		assert.Fail(t, "failed to create CA", err)
 		return
 	}
-	serverPrivKey, serverCert, err := createSignedCert(
-		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		caPrivKey,
-		caCert,
-	)
+	serverCert, err := ca.NewServerCert(certs.WithKeyType(certs.KeyTypeRSA))
 	if err != nil {
 		assert.Fail(t, "failed to create server cert", err)
 		return
@@ -113,9 +110,9 @@ func TestEncrypted(t *testing.T) {
 
 	clientConfig, serverConfig := createClientServerConfig()
 	clientConfig.URL = "https://127.0.0.1:8080"
-	clientConfig.CACert = string(caCertBytes)
-	serverConfig.Key = string(serverPrivKey)
-	serverConfig.Cert = string(serverCert)
+	clientConfig.CACert = ca.CertificatePEM()
+	serverConfig.Key = serverCert.PrivateKeyPEM()
+	serverConfig.Cert = serverCert.CertificatePEM()
 
 	message := "Hi"
 
@@ -130,44 +127,36 @@ func TestEncrypted(t *testing.T) {
 }
 
 func TestMutuallyAuthenticated(t *testing.T) {
-	caPrivKey, caCert, caCertBytes, err := createCA()
+	ca, err := certs.NewCA()
 	if err != nil {
 		assert.Fail(t, "failed to create CA", err)
 		return
 	}
-	serverPrivKey, serverCert, err := createSignedCert(
-		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		caPrivKey,
-		caCert,
-	)
+	serverCert, err := ca.NewServerCert()
 	if err != nil {
 		assert.Fail(t, "failed to create server cert", err)
 		return
 	}
 
-	clientCaPriv, clientCaCert, clientCaCertBytes, err := createCA()
+	clientCA, err := certs.NewCA()
 	if err != nil {
 		assert.Fail(t, "failed to create client CA", err)
 		return
 	}
-	clientPrivKey, clientCert, err := createSignedCert(
-		[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-		clientCaPriv,
-		clientCaCert,
-	)
+	clientCert, err := clientCA.NewClientCert()
 	if err != nil {
-		assert.Fail(t, "failed to create server cert", err)
+		assert.Fail(t, "failed to create client cert", err)
 		return
 	}
 
 	clientConfig, serverConfig := createClientServerConfig()
 	clientConfig.URL = "https://127.0.0.1:8080"
-	clientConfig.CACert = string(caCertBytes)
-	clientConfig.ClientCert = string(clientCert)
-	clientConfig.ClientKey = string(clientPrivKey)
-	serverConfig.Key = string(serverPrivKey)
-	serverConfig.Cert = string(serverCert)
-	serverConfig.ClientCACert = string(clientCaCertBytes)
+	clientConfig.CACert = ca.CertificatePEM()
+	clientConfig.ClientCert = clientCert.CertificatePEM()
+	clientConfig.ClientKey = clientCert.PrivateKeyPEM()
+	serverConfig.Key = serverCert.PrivateKeyPEM()
+	serverConfig.Cert = serverCert.CertificatePEM()
+	serverConfig.ClientCACert = clientCA.CertificatePEM()
 
 	message := "Hi"
 
@@ -182,45 +171,37 @@ func TestMutuallyAuthenticated(t *testing.T) {
 }
 
 func TestMutuallyAuthenticatedFailure(t *testing.T) {
-	caPrivKey, caCert, caCertBytes, err := createCA()
+	ca, err := certs.NewCA()
 	if err != nil {
 		assert.Fail(t, "failed to create CA", err)
 		return
 	}
-	serverPrivKey, serverCert, err := createSignedCert(
-		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		caPrivKey,
-		caCert,
-	)
+	serverCert, err := ca.NewServerCert()
 	if err != nil {
 		assert.Fail(t, "failed to create server cert", err)
 		return
 	}
 
-	clientCaPriv, clientCaCert, _, err := createCA()
+	clientCA, err := certs.NewCA()
 	if err != nil {
 		assert.Fail(t, "failed to create client CA", err)
 		return
 	}
-	clientPrivKey, clientCert, err := createSignedCert(
-		[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
-		clientCaPriv,
-		clientCaCert,
-	)
+	clientCert, err := clientCA.NewClientCert()
 	if err != nil {
-		assert.Fail(t, "failed to create server cert", err)
+		assert.Fail(t, "failed to create client cert", err)
 		return
 	}
 
 	clientConfig, serverConfig := createClientServerConfig()
 	clientConfig.URL = "https://127.0.0.1:8080"
-	clientConfig.CACert = string(caCertBytes)
-	clientConfig.ClientCert = string(clientCert)
-	clientConfig.ClientKey = string(clientPrivKey)
-	serverConfig.Key = string(serverPrivKey)
-	serverConfig.Cert = string(serverCert)
+	clientConfig.CACert = ca.CertificatePEM()
+	clientConfig.ClientCert = clientCert.CertificatePEM()
+	clientConfig.ClientKey = clientCert.PrivateKeyPEM()
+	serverConfig.Key = serverCert.PrivateKeyPEM()
+	serverConfig.Cert = serverCert.CertificatePEM()
 	//Pass wrong client CA cert to test failure
-	serverConfig.ClientCACert = string(caCertBytes)
+	serverConfig.ClientCACert = ca.CertificatePEM()
 
 	message := "Hi"
 
@@ -230,89 +211,6 @@ func TestMutuallyAuthenticatedFailure(t *testing.T) {
 	}
 }
 
-func createCA() (*rsa.PrivateKey, *x509.Certificate, []byte, error) {
-	ca := &x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization: []string{"ACME, Inc"},
-			Country:      []string{"US"},
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		BasicConstraintsValid: true,
-	}
-	caPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to create private key (%w)", err)
-	}
-	caCert, err := x509.CreateCertificate(rand.Reader, ca, ca, &caPrivateKey.PublicKey, caPrivateKey)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to create CA certificate (%w)", err)
-	}
-	caPEM := new(bytes.Buffer)
-	if err := pem.Encode(
-		caPEM,
-		&pem.Block{
-			Type:  "CERTIFICATE",
-			Bytes: caCert,
-		},
-	); err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to encode CA cert (%w)", err)
-	}
-	return caPrivateKey, ca, caPEM.Bytes(), nil
-}
-
-func createSignedCert(
-	usage []x509.ExtKeyUsage,
-	caPrivateKey *rsa.PrivateKey,
-	caCertificate *x509.Certificate,
-) ([]byte, []byte, error) {
-	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(1658),
-		Subject: pkix.Name{
-			Organization: []string{"ACME, Inc"},
-			Country:      []string{"US"},
-		},
-		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(0, 0, 1),
-		SubjectKeyId: []byte{1},
-		ExtKeyUsage:  usage,
-		KeyUsage:     x509.KeyUsageDigitalSignature,
-	}
-	certPrivKey, err := rsa.GenerateKey(rand.Reader, 4096)
-	if err != nil {
-		return nil, nil, err
-	}
-	certBytes, err := x509.CreateCertificate(
-		rand.Reader,
-		cert,
-		caCertificate,
-		&certPrivKey.PublicKey,
-		caPrivateKey,
-	)
-	if err != nil {
-		return nil, nil, err
-	}
-	certPrivKeyPEM := new(bytes.Buffer)
-	if err := pem.Encode(certPrivKeyPEM, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(certPrivKey),
-	}); err != nil {
-		return nil, nil, err
-	}
-	certPEM := new(bytes.Buffer)
-	if err := pem.Encode(certPEM,
-		&pem.Block{Type: "CERTIFICATE", Bytes: certBytes},
-	); err != nil {
-		return nil, nil, err
-	}
-	return certPrivKeyPEM.Bytes(), certPEM.Bytes(), nil
-}
-
 func runRequest(
 	clientConfig http.ClientConfiguration,
 	serverConfig http.ServerConfiguration,
@@ -367,3 +265,993 @@ func runRequest(
 	}
 	return response, responseStatus, nil
 }
+
+func TestHedgedRequest(t *testing.T) {
+	slowServer := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		select {
+		case <-request.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"error":false,"Message":"Hello world!"}`))
+	}))
+	defer fastServer.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = slowServer.URL
+	clientConfig.Hedging.Enabled = true
+	clientConfig.Hedging.Delay = 100 * time.Millisecond
+	clientConfig.Hedging.URLs = []string{fastServer.URL}
+
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		assert.Fail(t, "failed to create client", err)
+		return
+	}
+	response := Response{}
+	start := time.Now()
+	responseStatus, err := client.Get("/", &response)
+	if err != nil {
+		assert.Fail(t, "failed to run request", err)
+		return
+	}
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 200, responseStatus)
+	assert.Equal(t, "Hello world!", response.Message)
+}
+
+type tokenResponse struct {
+	AccessToken string `schema:"access_token"`
+	TokenType   string `schema:"token_type"`
+}
+
+func TestResponseContentTypes(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		switch request.URL.Path {
+		case "/token":
+			writer.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			_, _ = writer.Write([]byte("access_token=abc&token_type=bearer"))
+		case "/sniffed":
+			// Go sniffs the body as text/plain; charset=utf-8 since the Content-Type header is not set.
+			_, _ = writer.Write([]byte(`{"message":"Hello world!"}`))
+		case "/octet-stream":
+			writer.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = writer.Write([]byte(`{"message":"Hello world!"}`))
+		default:
+			writer.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = writer.Write([]byte{0, 1, 2})
+		}
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		assert.Fail(t, "failed to create client", err)
+		return
+	}
+
+	t.Run("form", func(t *testing.T) {
+		response := tokenResponse{}
+		responseStatus, err := client.Post("/token", &Request{Message: "Hi"}, &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, responseStatus)
+		assert.Equal(t, "abc", response.AccessToken)
+		assert.Equal(t, "bearer", response.TokenType)
+	})
+
+	t.Run("sniffed", func(t *testing.T) {
+		for _, path := range []string{"/sniffed", "/octet-stream"} {
+			response := Response{}
+			responseStatus, err := client.Post(path, &Request{Message: "Hi"}, &response)
+			assert.NoError(t, err)
+			assert.Equal(t, 200, responseStatus)
+			assert.Equal(t, "Hello world!", response.Message)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		response := Response{}
+		responseStatus, err := client.Post("/binary", &Request{Message: "Hi"}, &response)
+		assert.Equal(t, 200, responseStatus)
+		var typedErr log.Message
+		if !assert.True(t, errors.As(err, &typedErr)) {
+			return
+		}
+		assert.Equal(t, http.EClientUnsupportedContentType, typedErr.Code())
+	})
+}
+
+type echoResponse struct {
+	Authorization []string `json:"authorization"`
+	Extra         []string `json:"extra"`
+	Query         string   `json:"query"`
+}
+
+func TestRequestOptions(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		if request.URL.Path == "/slow" {
+			select {
+			case <-request.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		if request.URL.Path == "/created" {
+			writer.WriteHeader(201)
+		}
+		_ = json.NewEncoder(writer).Encode(echoResponse{
+			Authorization: request.Header.Values("Authorization"),
+			Extra:         request.Header.Values("X-Extra"),
+			Query:         request.URL.RawQuery,
+		})
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClientWithHeaders(
+		clientConfig,
+		log.NewTestLogger(t),
+		map[string][]string{
+			"Authorization": {"Bearer client"},
+			"X-Extra":       {"client"},
+		},
+		false,
+	)
+	if err != nil {
+		assert.Fail(t, "failed to create client", err)
+		return
+	}
+
+	t.Run("headers", func(t *testing.T) {
+		response := echoResponse{}
+		_, err := client.Get(
+			"/echo?a=1",
+			&response,
+			http.WithHeader("Authorization", "Bearer request"),
+			http.WithExtraHeader("X-Extra", "request"),
+			http.WithQueryParameter("b", "2"),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Bearer request"}, response.Authorization)
+		assert.Equal(t, []string{"client", "request"}, response.Extra)
+		assert.Equal(t, "a=1&b=2", response.Query)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.Get("/slow", nil, http.WithTimeout(200*time.Millisecond))
+		assert.Error(t, err)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+
+	t.Run("invalidTimeout", func(t *testing.T) {
+		for _, timeout := range []time.Duration{-time.Second, 50 * time.Millisecond} {
+			_, err := client.Get("/echo", nil, http.WithTimeout(timeout))
+			var typedErr log.Message
+			if assert.True(t, errors.As(err, &typedErr)) {
+				assert.Equal(t, http.EClientInvalidTimeout, typedErr.Code())
+			}
+		}
+	})
+
+	t.Run("expectedStatus", func(t *testing.T) {
+		response := echoResponse{}
+		statusCode, err := client.Post("/created", &Request{}, &response, http.WithExpectedStatus(201))
+		assert.NoError(t, err)
+		assert.Equal(t, 201, statusCode)
+
+		statusCode, err = client.Post("/echo", &Request{}, &response, http.WithExpectedStatus(201))
+		assert.Equal(t, 200, statusCode)
+		var typedErr log.Message
+		if assert.True(t, errors.As(err, &typedErr)) {
+			assert.Equal(t, http.EClientUnexpectedStatus, typedErr.Code())
+		}
+	})
+}
+
+type searchRequest struct {
+	User  string   `schema:"user"`
+	Scope []string `schema:"scope"`
+}
+
+type queryEchoResponse struct {
+	Method      string `json:"method"`
+	Query       string `json:"query"`
+	BodyLength  int    `json:"bodyLength"`
+	ContentType string `json:"contentType"`
+}
+
+func TestQueryEncoding(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(queryEchoResponse{
+			Method:      request.Method,
+			Query:       request.URL.RawQuery,
+			BodyLength:  len(body),
+			ContentType: request.Header.Get("Content-Type"),
+		})
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		assert.Fail(t, "failed to create client", err)
+		return
+	}
+	request := &searchRequest{User: "foo bar", Scope: []string{"read", "write"}}
+
+	response := queryEchoResponse{}
+	_, err = client.Get("/", &response, http.WithQueryObject(request))
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", response.Method)
+	assert.Equal(t, "scope=read&scope=write&user=foo+bar", response.Query)
+	assert.Equal(t, 0, response.BodyLength)
+	assert.Equal(t, "", response.ContentType)
+
+	response = queryEchoResponse{}
+	_, err = client.Delete("/", request, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE", response.Method)
+	assert.Equal(t, "scope=read&scope=write&user=foo+bar", response.Query)
+	assert.Equal(t, 0, response.BodyLength)
+
+	// Objects that cannot be encoded into the query string are sent as the body.
+	for _, body := range []interface{}{
+		map[string]interface{}{"user": "foo"},
+		json.RawMessage(`{"user":"foo"}`),
+	} {
+		response = queryEchoResponse{}
+		_, err = client.Delete("/", body, &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "", response.Query)
+		assert.NotEqual(t, 0, response.BodyLength)
+		assert.Equal(t, "application/json", response.ContentType)
+	}
+
+	statusCode, err := client.Head("/", http.WithQueryObject(map[string]string{"user": "baz"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, statusCode)
+
+	response = queryEchoResponse{}
+	_, err = client.Post("/", &Request{Message: "Hi"}, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "", response.Query)
+	assert.NotEqual(t, 0, response.BodyLength)
+	assert.Equal(t, "application/json", response.ContentType)
+}
+
+type protoResponse struct {
+	ProtoMajor int `json:"protoMajor"`
+}
+
+func TestHTTP2(t *testing.T) {
+	ca, err := certs.NewCA()
+	if err != nil {
+		assert.Fail(t, "failed to create CA", err)
+		return
+	}
+	serverCert, err := ca.NewServerCert()
+	if err != nil {
+		assert.Fail(t, "failed to create server cert", err)
+		return
+	}
+
+	for _, testCase := range []struct {
+		name               string
+		clientVersion      http.HTTPVersion
+		serverVersion      http.HTTPVersion
+		tls                bool
+		expectedProtoMajor int
+	}{
+		{"http1", http.HTTPVersion11, http.HTTPVersion11, false, 1},
+		{"http1-tls", http.HTTPVersion11, http.HTTPVersion2, true, 1},
+		{"http2-tls", http.HTTPVersion2, http.HTTPVersion2, true, 2},
+		{"http2-tls-fallback", http.HTTPVersion2, http.HTTPVersion11, true, 1},
+		{"h2c", http.HTTPVersionH2C, http.HTTPVersionH2C, false, 2},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			clientConfig, serverConfig := createClientServerConfig()
+			clientConfig.HTTPVersion = testCase.clientVersion
+			serverConfig.HTTPVersion = testCase.serverVersion
+			if testCase.tls {
+				clientConfig.URL = "https://127.0.0.1:8080"
+				clientConfig.CACert = ca.CertificatePEM()
+				serverConfig.Key = serverCert.PrivateKeyPEM()
+				serverConfig.Cert = serverCert.CertificatePEM()
+			}
+			stop, err := startServer(
+				t,
+				serverConfig,
+				goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+					writer.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(writer).Encode(protoResponse{ProtoMajor: request.ProtoMajor})
+				}),
+			)
+			if err != nil {
+				assert.Fail(t, "failed to start server", err)
+				return
+			}
+			defer stop()
+
+			client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+			if err != nil {
+				assert.Fail(t, "failed to create client", err)
+				return
+			}
+			for i := 0; i < 3; i++ {
+				response := protoResponse{}
+				if _, err := client.Get("/", &response); err != nil {
+					assert.Fail(t, "failed to run request", err)
+					return
+				}
+				assert.Equal(t, testCase.expectedProtoMajor, response.ProtoMajor)
+			}
+		})
+	}
+}
+
+func startServer(
+	t *testing.T,
+	serverConfig http.ServerConfiguration,
+	handler goHttp.Handler,
+) (func(), error) {
+	logger := log.NewTestLogger(t)
+	server, err := http.NewServer("HTTP", serverConfig, handler, logger, func(_ string) {})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server (%w)", err)
+	}
+	ready := make(chan bool, 1)
+	lifecycle := service.NewLifecycle(server)
+	lifecycle.OnRunning(func(s service.Service, l service.Lifecycle) {
+		ready <- true
+	})
+	errorChannel := make(chan error, 1)
+	go func() {
+		errorChannel <- lifecycle.Run()
+	}()
+	select {
+	case <-ready:
+	case err := <-errorChannel:
+		return nil, err
+	}
+	return func() {
+		lifecycle.Stop(context.Background())
+		<-errorChannel
+	}, nil
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		select {
+		case <-request.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	clientConfig.Timeout = 10 * time.Second
+	clientConfig.ResponseHeaderTimeout = 50 * time.Millisecond
+	_, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	assert.Error(t, err, "response header timeout below 100ms passed validation")
+
+	clientConfig.ResponseHeaderTimeout = 20 * time.Second
+	_, err = http.NewClient(clientConfig, log.NewTestLogger(t))
+	assert.Error(t, err, "response header timeout above the total timeout passed validation")
+
+	clientConfig.ResponseHeaderTimeout = 200 * time.Millisecond
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		assert.Fail(t, "failed to create client", err)
+		return
+	}
+	start := time.Now()
+	_, err = client.Get("/", nil)
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+
+	// A per-request timeout replaces the total timeout, so it cannot be lower than the response header timeout.
+	_, err = client.Get("/", nil, http.WithTimeout(150*time.Millisecond))
+	var typedErr log.Message
+	if !errors.As(err, &typedErr) || typedErr.Code() != http.EClientInvalidTimeout {
+		t.Fatalf("per-request timeout below the response header timeout did not fail (%v)", err)
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	target := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(echoResponse{
+			Authorization: request.Header.Values("Authorization"),
+			Extra:         request.Header.Values("X-Extra"),
+		})
+	}))
+	defer target.Close()
+	source := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		switch request.URL.Path {
+		case "/loop":
+			goHttp.Redirect(writer, request, "/loop", goHttp.StatusFound)
+		default:
+			goHttp.Redirect(writer, request, target.URL+"/target", goHttp.StatusFound)
+		}
+	}))
+	defer source.Close()
+
+	createClient := func(t *testing.T, policy http.RedirectPolicy) http.Client {
+		clientConfig, _ := createClientServerConfig()
+		clientConfig.URL = source.URL
+		clientConfig.AllowRedirects = true
+		clientConfig.RedirectPolicy = policy
+		client, err := http.NewClientWithHeaders(
+			clientConfig,
+			log.NewTestLogger(t),
+			map[string][]string{
+				"Authorization": {"Bearer secret"},
+				"X-Extra":       {"extra"},
+			},
+			false,
+		)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		return client
+	}
+	assertRedirectNotAllowed := func(t *testing.T, err error) {
+		var typedErr log.Message
+		if assert.True(t, errors.As(err, &typedErr)) {
+			assert.Equal(t, http.EClientRedirectNotAllowed, typedErr.Code())
+		}
+	}
+
+	t.Run("stripSensitiveHeaders", func(t *testing.T) {
+		response := echoResponse{}
+		_, err := createClient(t, http.RedirectPolicy{}).Get("/", &response)
+		assert.NoError(t, err)
+		assert.Empty(t, response.Authorization)
+		assert.Equal(t, []string{"extra"}, response.Extra)
+	})
+
+	t.Run("customSensitiveHeaders", func(t *testing.T) {
+		response := echoResponse{}
+		_, err := createClient(t, http.RedirectPolicy{SensitiveHeaders: []string{"X-Extra"}}).Get("/", &response)
+		assert.NoError(t, err)
+		assert.Empty(t, response.Extra)
+	})
+
+	t.Run("sameHost", func(t *testing.T) {
+		_, err := createClient(t, http.RedirectPolicy{SameHost: true}).Get("/", nil)
+		assertRedirectNotAllowed(t, err)
+	})
+
+	t.Run("allowedHosts", func(t *testing.T) {
+		_, err := createClient(t, http.RedirectPolicy{AllowedHosts: []string{"*.example.com"}}).Get("/", nil)
+		assertRedirectNotAllowed(t, err)
+
+		_, err = createClient(t, http.RedirectPolicy{AllowedHosts: []string{"127.0.0.1"}}).Get("/", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("maxRedirects", func(t *testing.T) {
+		_, err := createClient(t, http.RedirectPolicy{MaxRedirects: 3}).Get("/loop", nil)
+		assertRedirectNotAllowed(t, err)
+	})
+}
+
+func TestDestinationPolicy(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		if request.URL.Path == "/metadata" {
+			goHttp.Redirect(writer, request, "http://169.254.169.254/latest/meta-data/", goHttp.StatusFound)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"error":false,"Message":"Hello world!"}`))
+	}))
+	defer server.Close()
+
+	assertDenied := func(t *testing.T, err error) {
+		var typedErr log.Message
+		if assert.True(t, errors.As(err, &typedErr)) {
+			assert.Equal(t, http.EClientDestinationDenied, typedErr.Code())
+		}
+	}
+	request := func(t *testing.T, policy http.DestinationPolicy, path string) error {
+		clientConfig, _ := createClientServerConfig()
+		clientConfig.URL = server.URL
+		clientConfig.AllowRedirects = true
+		clientConfig.DestinationPolicy = policy
+		client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		_, err = client.Get(path, nil)
+		return err
+	}
+
+	t.Run("loopbackDeniedByDefault", func(t *testing.T) {
+		assertDenied(t, request(t, http.DestinationPolicy{}, "/"))
+	})
+	t.Run("allowLoopback", func(t *testing.T) {
+		assert.NoError(t, request(t, http.DestinationPolicy{AllowLoopback: true}, "/"))
+	})
+	t.Run("allowList", func(t *testing.T) {
+		assert.NoError(t, request(t, http.DestinationPolicy{Allow: []string{"127.0.0.0/8"}}, "/"))
+		assertDenied(t, request(t, http.DestinationPolicy{Allow: []string{"10.0.0.0/8"}, AllowLoopback: true}, "/"))
+	})
+	t.Run("denyList", func(t *testing.T) {
+		assertDenied(t, request(t, http.DestinationPolicy{Deny: []string{"127.0.0.1"}, AllowLoopback: true}, "/"))
+	})
+	t.Run("redirect", func(t *testing.T) {
+		assertDenied(t, request(t, http.DestinationPolicy{AllowLoopback: true}, "/metadata"))
+	})
+}
+
+type cookieResponse struct {
+	Affinity string `json:"affinity"`
+}
+
+func TestCookieJar(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		affinity := ""
+		if cookie, err := request.Cookie("affinity"); err == nil {
+			affinity = cookie.Value
+		} else {
+			goHttp.SetCookie(writer, &goHttp.Cookie{Name: "affinity", Value: "backend-1", Path: "/"})
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(cookieResponse{Affinity: affinity})
+	}))
+	defer server.Close()
+
+	jarFile := filepath.Join(t.TempDir(), "cookies.json")
+	createClient := func(t *testing.T, enabled bool) http.Client {
+		clientConfig, _ := createClientServerConfig()
+		clientConfig.URL = server.URL
+		clientConfig.CookieJar.Enabled = enabled
+		if enabled {
+			clientConfig.CookieJar.File = jarFile
+		}
+		client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		return client
+	}
+	getAffinity := func(t *testing.T, client http.Client) string {
+		response := cookieResponse{}
+		if _, err := client.Get("/", &response); err != nil {
+			t.Fatalf("request failed (%v)", err)
+		}
+		return response.Affinity
+	}
+
+	withoutJar := createClient(t, false)
+	assert.Equal(t, "", getAffinity(t, withoutJar))
+	assert.Equal(t, "", getAffinity(t, withoutJar))
+
+	withJar := createClient(t, true)
+	assert.Equal(t, "", getAffinity(t, withJar))
+	assert.Equal(t, "backend-1", getAffinity(t, withJar))
+
+	// A new client loads the cookies from the file, simulating a restart.
+	restarted := createClient(t, true)
+	assert.Equal(t, "backend-1", getAffinity(t, restarted))
+}
+
+type fixtureResponse struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestFixtures(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		count++
+		writer.Header().Set("Content-Type", "application/json")
+		goHttp.SetCookie(writer, &goHttp.Cookie{Name: "session", Value: "session-secret"})
+		_ = json.NewEncoder(writer).Encode(fixtureResponse{Name: request.URL.Path, Count: count})
+	}))
+
+	fixtureFile := filepath.Join(t.TempDir(), "fixtures.json")
+	createClient := func(t *testing.T, mode http.FixtureMode) http.Client {
+		clientConfig, _ := createClientServerConfig()
+		clientConfig.URL = server.URL
+		clientConfig.Fixtures = http.FixtureConfiguration{
+			Mode:    mode,
+			File:    fixtureFile,
+			MatchOn: []http.FixtureMatchField{http.FixtureMatchMethod, http.FixtureMatchPath, http.FixtureMatchBody},
+			// Authorization is redacted, so only its presence is matched.
+			MatchHeaders: []string{"Authorization"},
+		}
+		client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		return client
+	}
+
+	recorder := createClient(t, http.FixtureModeRecord)
+	response := fixtureResponse{}
+	for i := 1; i <= 2; i++ {
+		if _, err := recorder.Post(
+			"/users",
+			map[string]string{"user": "foo", "password": "password-secret"},
+			&response,
+			http.WithHeader("Authorization", "Bearer token-secret"),
+		); err != nil {
+			t.Fatalf("request failed (%v)", err)
+		}
+		assert.Equal(t, i, response.Count)
+	}
+	server.Close()
+
+	recorded, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, string(recorded), "token-secret")
+	assert.NotContains(t, string(recorded), "session-secret")
+	assert.NotContains(t, string(recorded), "password-secret")
+
+	replayer := createClient(t, http.FixtureModeReplay)
+	// Matching fixtures are replayed in order, then the last one is repeated.
+	for _, expected := range []int{1, 2, 2} {
+		response = fixtureResponse{}
+		if _, err := replayer.Post(
+			"/users",
+			map[string]string{"user": "foo", "password": "password-secret"},
+			&response,
+			http.WithHeader("Authorization", "Bearer other-token"),
+		); err != nil {
+			t.Fatalf("replay failed (%v)", err)
+		}
+		assert.Equal(t, "/users", response.Name)
+		assert.Equal(t, expected, response.Count)
+	}
+
+	_, err = replayer.Post("/users", map[string]string{"user": "foo", "password": "password-secret"}, &response)
+	var typedErr log.Message
+	if !errors.As(err, &typedErr) || typedErr.Code() != http.EClientFixtureNotFound {
+		t.Fatalf("request without the matched header did not fail with a fixture error (%v)", err)
+	}
+
+	_, err = replayer.Post(
+		"/users",
+		map[string]string{"user": "bar"},
+		&response,
+		http.WithHeader("Authorization", "Bearer token-secret"),
+	)
+	if !errors.As(err, &typedErr) || typedErr.Code() != http.EClientFixtureNotFound {
+		t.Fatalf("unmatched request did not fail with a fixture error (%v)", err)
+	}
+}
+
+func TestFixturesBinaryBody(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		writer.Header().Set("Content-Type", request.Header.Get("Content-Type"))
+		_, _ = writer.Write(body)
+	}))
+
+	fixtureFile := filepath.Join(t.TempDir(), "fixtures.json")
+	createClient := func(t *testing.T, mode http.FixtureMode) http.Client {
+		clientConfig, _ := createClientServerConfig()
+		clientConfig.URL = server.URL
+		clientConfig.RequestEncoding = http.RequestEncodingMessagePack
+		clientConfig.Fixtures = http.FixtureConfiguration{
+			Mode:    mode,
+			File:    fixtureFile,
+			MatchOn: []http.FixtureMatchField{http.FixtureMatchMethod, http.FixtureMatchPath, http.FixtureMatchBody},
+		}
+		client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		return client
+	}
+
+	// The MessagePack encoding of 200 is not valid UTF-8.
+	request := fixtureResponse{Name: "foo", Count: 200}
+	response := fixtureResponse{}
+	if _, err := createClient(t, http.FixtureModeRecord).Post("/echo", request, &response); err != nil {
+		t.Fatalf("request failed (%v)", err)
+	}
+	assert.Equal(t, request, response)
+	server.Close()
+
+	recorded, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(recorded), `"base64"`)
+	// The request body cannot be redacted, so only its digest is recorded.
+	assert.Contains(t, string(recorded), `"body": "sha256:`)
+
+	response = fixtureResponse{}
+	if _, err := createClient(t, http.FixtureModeReplay).Post("/echo", request, &response); err != nil {
+		t.Fatalf("replay failed (%v)", err)
+	}
+	assert.Equal(t, request, response)
+}
+
+func TestFixturesRecordStream(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		writer.Header().Set("Content-Type", "text/event-stream")
+		_, _ = writer.Write([]byte("data: {}\n\n"))
+		writer.(goHttp.Flusher).Flush()
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+
+	fixtureFile := filepath.Join(t.TempDir(), "fixtures.json")
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	clientConfig.Fixtures = http.FixtureConfiguration{Mode: http.FixtureModeRecord, File: fixtureFile}
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	// The stream never ends, so the event is only received if the recording does not wait for the whole body.
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan http.Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Subscribe(ctx, "/", nil, func(event http.Event) { events <- event })
+	}()
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription did not stop after the context was canceled")
+	}
+
+	recorded, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(recorded), `"body": "data: {}\n\n"`)
+}
+
+type configChange struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func TestSubscribe(t *testing.T) {
+	var lastEventIDs []string
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		lastEventIDs = append(lastEventIDs, request.Header.Get("Last-Event-ID"))
+		switch len(lastEventIDs) {
+		case 1:
+			writer.Header().Set("Content-Type", "text/event-stream")
+			_, _ = writer.Write([]byte(
+				"retry: 10\n\n" +
+					"event: change\nid: 1\ndata: {\"key\":\"a\",\"value\":\"1\"}\n\n" +
+					"event: heartbeat\ndata: ping\n\n" +
+					"event: change\nid: 2\ndata: {\"invalid\":true}\n\n",
+			))
+		case 2:
+			writer.Header().Set("Content-Type", "text/event-stream")
+			_, _ = writer.Write([]byte("event: change\nid: 3\ndata: {\"key\":\"b\",\"value\":\"2\"}\n\n"))
+		case 3:
+			writer.WriteHeader(goHttp.StatusServiceUnavailable)
+		default:
+			writer.WriteHeader(goHttp.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	var events []http.Event
+	err = client.Subscribe(
+		context.Background(),
+		"/changes",
+		func(eventType string) interface{} {
+			if eventType == "change" {
+				return &configChange{}
+			}
+			return nil
+		},
+		func(event http.Event) {
+			events = append(events, event)
+		},
+	)
+	if err != nil {
+		t.Fatalf("subscription failed (%v)", err)
+	}
+
+	assert.Equal(t, []string{"", "2", "3", "3"}, lastEventIDs)
+	// The event that fails to decode is skipped.
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "change", events[0].Type)
+	assert.Equal(t, "1", events[0].ID)
+	assert.Equal(t, &configChange{Key: "a", Value: "1"}, events[0].Value)
+	assert.Equal(t, "heartbeat", events[1].Type)
+	assert.Equal(t, "ping", string(events[1].Data))
+	assert.Nil(t, events[1].Value)
+	assert.Equal(t, &configChange{Key: "b", Value: "2"}, events[2].Value)
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		writer.Header().Set("Content-Type", "text/event-stream")
+		_, _ = writer.Write([]byte("data: {}\n\n"))
+		writer.(goHttp.Flusher).Flush()
+		<-request.Context().Done()
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan http.Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Subscribe(ctx, "/", nil, func(event http.Event) { events <- event })
+	}()
+	select {
+	case <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event received")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription did not stop after the context was canceled")
+	}
+}
+
+func TestSubscribeUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusForbidden)
+	}))
+	defer server.Close()
+
+	clientConfig, _ := createClientServerConfig()
+	clientConfig.URL = server.URL
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	err = client.Subscribe(context.Background(), "/", nil, func(event http.Event) {})
+	var typedErr log.Message
+	if !errors.As(err, &typedErr) || typedErr.Code() != http.EClientUnexpectedStatus {
+		t.Fatalf("subscription did not fail with an unexpected status error (%v)", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tlsServer := mock.Start(t, mock.Config{TLS: true})
+	tlsServer.On("GET", "/health", mock.Response{StatusCode: 204})
+	plainServer := mock.Start(t, mock.Config{})
+	plainServer.On("HEAD", "/", mock.Response{StatusCode: 503})
+
+	otherCA, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		name        string
+		config      func() http.ClientConfiguration
+		failedStage http.HealthStage
+		statuses    []http.HealthStatus
+	}{
+		{
+			"tls",
+			func() http.ClientConfiguration {
+				config := tlsServer.ClientConfiguration()
+				config.HealthCheck.Path = "/health"
+				return config
+			},
+			"",
+			[]http.HealthStatus{http.HealthStatusPassed, http.HealthStatusPassed, http.HealthStatusPassed, http.HealthStatusPassed},
+		},
+		{
+			"untrusted-ca",
+			func() http.ClientConfiguration {
+				config := tlsServer.ClientConfiguration()
+				config.HealthCheck.Path = "/health"
+				config.CACert = otherCA.CertificatePEM()
+				return config
+			},
+			http.HealthStageTLS,
+			[]http.HealthStatus{http.HealthStatusPassed, http.HealthStatusPassed, http.HealthStatusFailed, http.HealthStatusSkipped},
+		},
+		{
+			"unexpected-status",
+			func() http.ClientConfiguration {
+				config := plainServer.ClientConfiguration()
+				config.HealthCheck.Method = "HEAD"
+				return config
+			},
+			http.HealthStageHTTP,
+			[]http.HealthStatus{http.HealthStatusPassed, http.HealthStatusPassed, http.HealthStatusSkipped, http.HealthStatusFailed},
+		},
+		{
+			"expected-status",
+			func() http.ClientConfiguration {
+				config := plainServer.ClientConfiguration()
+				config.HealthCheck.Method = "HEAD"
+				config.HealthCheck.ExpectedStatus = []int{503}
+				return config
+			},
+			"",
+			[]http.HealthStatus{http.HealthStatusPassed, http.HealthStatusPassed, http.HealthStatusSkipped, http.HealthStatusPassed},
+		},
+		{
+			"destination-denied",
+			func() http.ClientConfiguration {
+				config := plainServer.ClientConfiguration()
+				config.DestinationPolicy.AllowLoopback = false
+				return config
+			},
+			http.HealthStageTCP,
+			[]http.HealthStatus{http.HealthStatusPassed, http.HealthStatusFailed, http.HealthStatusSkipped, http.HealthStatusSkipped},
+		},
+		{
+			"dns",
+			func() http.ClientConfiguration {
+				config := plainServer.ClientConfiguration()
+				config.URL = "http://nonexistent.invalid/"
+				return config
+			},
+			http.HealthStageDNS,
+			[]http.HealthStatus{http.HealthStatusFailed, http.HealthStatusSkipped, http.HealthStatusSkipped, http.HealthStatusSkipped},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, err := http.NewClient(testCase.config(), log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			report := client.HealthCheck(context.Background())
+			var statuses []http.HealthStatus
+			for _, stage := range report.Stages {
+				statuses = append(statuses, stage.Status)
+			}
+			assert.Equal(t, testCase.statuses, statuses)
+			if testCase.failedStage == "" {
+				assert.True(t, report.Healthy())
+				assert.NoError(t, report.Err())
+				return
+			}
+			assert.False(t, report.Healthy())
+			assert.Equal(t, testCase.failedStage, report.FailedStage().Stage)
+			var typedErr log.Message
+			if !errors.As(report.Err(), &typedErr) || typedErr.Code() != http.EClientHealthCheckFailed {
+				t.Fatalf("unexpected health check error (%v)", report.Err())
+			}
+		})
+	}
+}
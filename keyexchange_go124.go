@@ -0,0 +1,13 @@
+//go:build !go1.25
+
+package http
+
+import (
+	"crypto/tls"
+)
+
+// negotiatedKeyExchange returns the name of the key exchange negotiated for the connection. Go versions before 1.25
+// do not report the key exchange.
+func negotiatedKeyExchange(_ tls.ConnectionState) string {
+	return "unknown"
+}
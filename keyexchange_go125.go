@@ -0,0 +1,12 @@
+//go:build go1.25
+
+package http
+
+import (
+	"crypto/tls"
+)
+
+// negotiatedKeyExchange returns the name of the key exchange negotiated for the connection.
+func negotiatedKeyExchange(state tls.ConnectionState) string {
+	return state.CurveID.String()
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// loadCertificate loads a certificate and its key either from PEM, decrypting the key if it is an encrypted PKCS #8
+// key, or from a PKCS #12 file.
+func loadCertificate(
+	certSpec string,
+	keySpec string,
+	pkcs12File string,
+	passphraseConfig PassphraseConfiguration,
+) (*tls.Certificate, error) {
+	passphrase, err := passphraseConfig.load()
+	if err != nil {
+		return nil, err
+	}
+	if pkcs12File != "" {
+		data, err := loadSecret(pkcs12File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PKCS #12 file (%w)", err)
+		}
+		return parsePKCS12(data, passphrase)
+	}
+	certPEM, err := loadPem(certSpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate (%w)", err)
+	}
+	keyPEM, err := loadPem(keySpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key (%w)", err)
+	}
+	return parseKeyPair(certPEM, keyPEM, passphrase)
+}
+
+// parseKeyPair parses a PEM certificate chain and key. An encrypted PKCS #8 key is decrypted with the passphrase.
+func parseKeyPair(certPEM []byte, keyPEM []byte, passphrase []byte) (*tls.Certificate, error) {
+	if block, _ := pem.Decode(keyPEM); block != nil && block.Type == "ENCRYPTED PRIVATE KEY" {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("the key is encrypted, but no passphrase is configured")
+		}
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key (%w)", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key (%w)", err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// parsePKCS12 parses a PKCS #12 bundle containing a certificate, its chain and the key.
+func parsePKCS12(data []byte, passphrase []byte) (*tls.Certificate, error) {
+	key, cert, chain, err := pkcs12.DecodeChain(data, string(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PKCS #12 file (%w)", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type in PKCS #12 file: %T", key)
+	}
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(cert.PublicKey) {
+		return nil, fmt.Errorf("the private key in the PKCS #12 file does not match the certificate")
+	}
+	result := &tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, chainCert := range chain {
+		result.Certificate = append(result.Certificate, chainCert.Raw)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package http_test
+
+import (
+	"io/ioutil"
+	goHttp "net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+)
+
+func TestEncryptedKeys(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, err := ca.NewIntermediate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := intermediate.NewServerCert(certs.WithKeyType(certs.KeyTypeRSA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := intermediate.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedKey, err := serverCert.EncryptedPrivateKeyPEM("server secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := clientCert.PKCS12("client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfxFile := filepath.Join(dir, "client.p12")
+	passphraseFile := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(pfxFile, pfx, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(passphraseFile, []byte("client secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HTTP_TEST_SERVER_PASSPHRASE", "server secret")
+
+	clientConfig, serverConfig := createClientServerConfig()
+	clientConfig.URL = "https://127.0.0.1:8080"
+	clientConfig.CACert = ca.CertificatePEM()
+	clientConfig.ClientPKCS12 = pfxFile
+	clientConfig.KeyPassphrase.Ref = "file:" + passphraseFile
+	serverConfig.Cert = serverCert.ChainPEM()
+	serverConfig.Key = encryptedKey
+	serverConfig.KeyPassphrase.Ref = "env:HTTP_TEST_SERVER_PASSPHRASE"
+	serverConfig.ClientCACert = ca.CertificatePEM()
+
+	t.Run("valid", func(t *testing.T) {
+		stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+			writer.WriteHeader(goHttp.StatusNoContent)
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusCode, err := client.Head("/")
+		assert.NoError(t, err)
+		assert.Equal(t, goHttp.StatusNoContent, statusCode)
+	})
+
+	t.Run("wrong-passphrase", func(t *testing.T) {
+		config := serverConfig
+		config.KeyPassphrase = http.PassphraseConfiguration{Value: "wrong"}
+		assert.Error(t, config.Validate())
+
+		clientConfig := clientConfig
+		clientConfig.KeyPassphrase = http.PassphraseConfiguration{Value: "wrong"}
+		assert.Error(t, clientConfig.Validate())
+	})
+
+	t.Run("missing-passphrase", func(t *testing.T) {
+		config := serverConfig
+		config.KeyPassphrase = http.PassphraseConfiguration{}
+		assert.Error(t, config.Validate())
+	})
+
+	t.Run("multiple-sources", func(t *testing.T) {
+		config := serverConfig
+		config.KeyPassphrase = http.PassphraseConfiguration{Value: "server secret", Ref: "env:HTTP_TEST_SERVER_PASSPHRASE"}
+		assert.Error(t, config.Validate())
+	})
+
+	t.Run("deprecated-fields", func(t *testing.T) {
+		config := serverConfig
+		config.KeyPassphrase = http.PassphraseConfiguration{Env: "HTTP_TEST_SERVER_PASSPHRASE"}
+		assert.NoError(t, config.Validate())
+
+		clientConfig := clientConfig
+		clientConfig.KeyPassphrase = http.PassphraseConfiguration{File: passphraseFile}
+		assert.NoError(t, clientConfig.Validate())
+
+		config.KeyPassphrase = http.PassphraseConfiguration{
+			Ref: "env:HTTP_TEST_SERVER_PASSPHRASE",
+			Env: "HTTP_TEST_SERVER_PASSPHRASE",
+		}
+		assert.Error(t, config.Validate())
+	})
+
+	t.Run("invalid-reference", func(t *testing.T) {
+		config := serverConfig
+		config.KeyPassphrase = http.PassphraseConfiguration{Ref: "HTTP_TEST_SERVER_PASSPHRASE"}
+		assert.Error(t, config.Validate())
+	})
+
+	t.Run("literal", func(t *testing.T) {
+		// A literal passphrase looking like a reference is used as it is.
+		key, err := serverCert.EncryptedPrivateKeyPEM("env:HTTP_TEST_SERVER_PASSPHRASE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := serverConfig
+		config.Key = key
+		config.KeyPassphrase = http.PassphraseConfiguration{Value: "env:HTTP_TEST_SERVER_PASSPHRASE"}
+		assert.NoError(t, config.Validate())
+	})
+}
@@ -0,0 +1,4 @@
+// Package mock provides an in-process webhook server for testing clients built on this library. The server listens on
+// an ephemeral port on the loopback interface, answers with scripted responses per method and path, and records the
+// requests it receives. It can optionally serve TLS or mutual TLS with generated certificates.
+package mock
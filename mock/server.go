@@ -0,0 +1,260 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	goHttp "net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+	"github.com/containerssh/log"
+	"github.com/containerssh/service"
+	"github.com/containerssh/structutils"
+)
+
+// Config is the configuration of the mock server.
+type Config struct {
+	// TLS enables serving HTTPS with a generated certificate. The client configuration trusts the generated CA.
+	TLS bool
+	// MutualTLS enables TLS and requires the client to present a certificate signed by the generated CA. The client
+	// configuration contains a generated client certificate.
+	MutualTLS bool
+	// HTTPVersion is the HTTP version to use on both the server and the client. If empty, the defaults apply.
+	HTTPVersion http.HTTPVersion
+}
+
+// Response is a scripted response of the mock server.
+type Response struct {
+	// StatusCode is the HTTP status code. Defaults to 200.
+	StatusCode int
+	// Headers are sent with the response.
+	Headers map[string][]string
+	// Body is the response body. Strings and byte slices are sent as-is, everything else is encoded as JSON.
+	Body interface{}
+	// Delay is the time to wait before sending the response, for testing timeouts.
+	Delay time.Duration
+}
+
+// Request is a request received by the mock server.
+type Request struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers goHttp.Header
+	Body    []byte
+}
+
+// JSON decodes the request body into target.
+func (r Request) JSON(target interface{}) error {
+	return json.Unmarshal(r.Body, target)
+}
+
+// Server is a running mock webhook server.
+type Server struct {
+	url          string
+	clientConfig http.ClientConfiguration
+	lifecycle    service.Lifecycle
+	errors       chan error
+	lock         *sync.Mutex
+	responses    map[string][]Response
+	served       map[string]int
+	requests     []Request
+	stopOnce     *sync.Once
+}
+
+// Start starts a mock server listening on an ephemeral port on 127.0.0.1. The server is stopped when the test
+// finishes. Requests without a scripted response are answered with 404.
+func Start(t *testing.T, config Config) *Server {
+	logger := log.NewTestLogger(t)
+	serverConfig := http.ServerConfiguration{}
+	clientConfig := http.ClientConfiguration{}
+	structutils.Defaults(&serverConfig)
+	structutils.Defaults(&clientConfig)
+	serverConfig.Listen = "127.0.0.1:0"
+	clientConfig.DestinationPolicy.AllowLoopback = true
+	if config.HTTPVersion != http.HTTPVersionDefault {
+		serverConfig.HTTPVersion = config.HTTPVersion
+		clientConfig.HTTPVersion = config.HTTPVersion
+	}
+	if config.TLS || config.MutualTLS {
+		ca, err := certs.NewCA(certs.WithCommonName("Mock CA"))
+		if err != nil {
+			t.Fatalf("failed to create CA for the mock server (%v)", err)
+		}
+		serverCert, err := ca.NewServerCert()
+		if err != nil {
+			t.Fatalf("failed to create certificate for the mock server (%v)", err)
+		}
+		serverConfig.Cert = serverCert.CertificatePEM()
+		serverConfig.Key = serverCert.PrivateKeyPEM()
+		clientConfig.CACert = ca.CertificatePEM()
+		if config.MutualTLS {
+			clientCert, err := ca.NewClientCert(certs.WithCommonName("mock-client"))
+			if err != nil {
+				t.Fatalf("failed to create client certificate for the mock server (%v)", err)
+			}
+			serverConfig.ClientCACert = ca.CertificatePEM()
+			clientConfig.ClientCert = clientCert.CertificatePEM()
+			clientConfig.ClientKey = clientCert.PrivateKeyPEM()
+		}
+	}
+
+	s := &Server{
+		errors:    make(chan error, 1),
+		lock:      &sync.Mutex{},
+		responses: map[string][]Response{},
+		served:    map[string]int{},
+		stopOnce:  &sync.Once{},
+	}
+	srv, err := http.NewServer("mock", serverConfig, goHttp.HandlerFunc(s.handle), logger, func(url string) {
+		s.url = url
+	})
+	if err != nil {
+		t.Fatalf("failed to create mock server (%v)", err)
+	}
+
+	ready := make(chan bool, 1)
+	s.lifecycle = service.NewLifecycle(srv)
+	s.lifecycle.OnRunning(func(s service.Service, l service.Lifecycle) {
+		ready <- true
+	})
+	go func() {
+		s.errors <- s.lifecycle.Run()
+	}()
+	select {
+	case <-ready:
+	case err := <-s.errors:
+		t.Fatalf("failed to start mock server (%v)", err)
+	}
+	t.Cleanup(s.Stop)
+
+	clientConfig.URL = s.url
+	s.clientConfig = clientConfig
+	return s
+}
+
+// URL returns the base URL of the server, for example http://127.0.0.1:41234.
+func (s *Server) URL() string {
+	return s.url
+}
+
+// ClientConfiguration returns a client configuration pointing at the server. It allows connecting to loopback
+// addresses and contains the CA and client certificate if TLS is enabled.
+func (s *Server) ClientConfiguration() http.ClientConfiguration {
+	return s.clientConfig
+}
+
+// On scripts the responses for a method and path. The responses are sent in order, and the last one is repeated once
+// all have been sent. Calling On again for the same method and path replaces the responses.
+func (s *Server) On(method string, path string, responses ...Response) {
+	if len(responses) == 0 {
+		panic("BUG: no responses provided to mock.Server.On")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := method + " " + path
+	s.responses[key] = responses
+	s.served[key] = 0
+}
+
+// Requests returns the requests received so far in the order they arrived.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]Request, len(s.requests))
+	copy(result, s.requests)
+	return result
+}
+
+// Stop stops the server. It is called automatically when the test finishes.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		s.lifecycle.Stop(context.Background())
+		<-s.errors
+	})
+}
+
+func (s *Server) handle(writer goHttp.ResponseWriter, request *goHttp.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(goHttp.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	s.requests = append(s.requests, Request{
+		Method:  request.Method,
+		Path:    request.URL.Path,
+		Query:   request.URL.Query(),
+		Headers: request.Header.Clone(),
+		Body:    body,
+	})
+	key := request.Method + " " + request.URL.Path
+	responses, ok := s.responses[key]
+	var response Response
+	if ok {
+		index := s.served[key]
+		if index >= len(responses) {
+			index = len(responses) - 1
+		}
+		response = responses[index]
+		s.served[key]++
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		response = Response{
+			StatusCode: goHttp.StatusNotFound,
+			Body:       map[string]string{"error": fmt.Sprintf("no response scripted for %s", key)},
+		}
+	}
+	s.respond(writer, request, response)
+}
+
+func (s *Server) respond(writer goHttp.ResponseWriter, request *goHttp.Request, response Response) {
+	if response.Delay > 0 {
+		select {
+		case <-time.After(response.Delay):
+		case <-request.Context().Done():
+			return
+		}
+	}
+	var body []byte
+	contentType := ""
+	switch typedBody := response.Body.(type) {
+	case nil:
+	case []byte:
+		body = typedBody
+	case string:
+		body = []byte(typedBody)
+		contentType = "text/plain; charset=utf-8"
+	default:
+		var err error
+		body, err = json.Marshal(typedBody)
+		if err != nil {
+			writer.WriteHeader(goHttp.StatusInternalServerError)
+			return
+		}
+		contentType = "application/json"
+	}
+	for name, values := range response.Headers {
+		for _, value := range values {
+			writer.Header().Add(name, value)
+		}
+	}
+	if contentType != "" && writer.Header().Get("Content-Type") == "" {
+		writer.Header().Set("Content-Type", contentType)
+	}
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = goHttp.StatusOK
+	}
+	writer.WriteHeader(statusCode)
+	_, _ = writer.Write(body)
+}
@@ -0,0 +1,71 @@
+package mock_test
+
+import (
+	"testing"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/mock"
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func TestMockServer(t *testing.T) {
+	for name, config := range map[string]mock.Config{
+		"plain": {},
+		"tls":   {TLS: true},
+		"mtls":  {MutualTLS: true},
+		"http2": {TLS: true, HTTPVersion: http.HTTPVersion2},
+		"h2c":   {HTTPVersion: http.HTTPVersionH2C},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := mock.Start(t, config)
+			server.On(
+				"POST",
+				"/users",
+				mock.Response{StatusCode: 201, Body: user{Name: "foo"}},
+				mock.Response{StatusCode: 409, Body: user{Name: "bar"}},
+			)
+
+			client, err := http.NewClient(server.ClientConfiguration(), log.NewTestLogger(t))
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			for _, expected := range []struct {
+				status int
+				name   string
+			}{{201, "foo"}, {409, "bar"}, {409, "bar"}} {
+				response := user{}
+				status, err := client.Post("/users", user{Name: "request"}, &response)
+				if err != nil {
+					t.Fatalf("request failed (%v)", err)
+				}
+				assert.Equal(t, expected.status, status)
+				assert.Equal(t, expected.name, response.Name)
+			}
+
+			notFound := map[string]string{}
+			status, err := client.Get("/missing", &notFound, http.WithQueryParameter("a", "b"))
+			if err != nil {
+				t.Fatalf("request failed (%v)", err)
+			}
+			assert.Equal(t, 404, status)
+			assert.Contains(t, notFound["error"], "GET /missing")
+
+			requests := server.Requests()
+			assert.Equal(t, 4, len(requests))
+			assert.Equal(t, "POST", requests[0].Method)
+			assert.Equal(t, "/users", requests[0].Path)
+			received := user{}
+			if err := requests[0].JSON(&received); err != nil {
+				t.Fatalf("failed to decode recorded request (%v)", err)
+			}
+			assert.Equal(t, "request", received.Name)
+			assert.Equal(t, "GET", requests[3].Method)
+			assert.Equal(t, "b", requests[3].Query.Get("a"))
+		})
+	}
+}
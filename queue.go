@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/containerssh/service"
+)
+
+// Queue delivers requests to a webhook with at-least-once semantics. Requests are persisted to disk before Enqueue
+// returns and are retried with exponential backoff until they succeed or run out of attempts, even across restarts.
+// Deliveries only happen while the queue is running as a service.
+type Queue interface {
+	service.Service
+
+	// Enqueue persists a request for delivery. Requests with the same key are delivered in the order they were
+	// enqueued; a request is only sent once all earlier requests with the same key have been delivered or
+	// dead-lettered. The body is stored as JSON, so the client must use the JSON request encoding.
+	Enqueue(key string, method string, path string, body interface{}) error
+
+	// Depth returns the number of requests waiting for delivery, not including dead-lettered requests.
+	Depth() int
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/containerssh/log"
+)
+
+// NewQueue creates a delivery queue sending requests with the client. Pending requests from a previous run are
+// loaded from the queue directory. Queued request bodies are stored as JSON, so clients created by NewClient must
+// use the JSON request encoding.
+func NewQueue(config QueueConfiguration, client Client, logger log.Logger) (Queue, error) {
+	if client == nil {
+		panic("BUG: no client provided to http.NewQueue")
+	}
+	if logger == nil {
+		panic("BUG: no logger provided to http.NewQueue")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if encoding, ok := clientRequestEncoding(client); ok &&
+		encoding != RequestEncodingDefault && encoding != RequestEncodingJSON {
+		return nil, fmt.Errorf(
+			"the delivery queue stores request bodies as JSON and cannot be used with the %s request encoding",
+			encoding,
+		)
+	}
+
+	q := &queue{
+		config:  config,
+		client:  client,
+		logger:  logger.WithLabel("queue", config.Directory),
+		lock:    &sync.Mutex{},
+		pending: map[string][]*queueItem{},
+		workers: map[string]bool{},
+		wg:      &sync.WaitGroup{},
+	}
+	for _, dir := range []string{q.pendingDir(), q.deadDir()} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create queue directory %s (%w)", dir, err)
+		}
+	}
+	if err := q.load(); err != nil {
+		return nil, fmt.Errorf("failed to load queue from %s (%w)", config.Directory, err)
+	}
+	return q, nil
+}
+
+// clientRequestEncoding returns the request encoding of a client created by NewClient.
+func clientRequestEncoding(c Client) (RequestEncoding, bool) {
+	impl, ok := c.(*client)
+	if !ok {
+		return "", false
+	}
+	return impl.config.RequestEncoding, true
+}
@@ -0,0 +1,332 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerssh/log"
+	"github.com/containerssh/service"
+)
+
+const (
+	defaultQueueMaxAttempts    = 10
+	defaultQueueInitialBackoff = time.Second
+	defaultQueueMaxBackoff     = 5 * time.Minute
+)
+
+// queueItem is a request waiting for delivery. It is stored as a JSON file named after its sequence number, so
+// sorting the file names restores the order the requests were enqueued in. For methods without a body, request
+// objects the client would encode into the query string are stored in Query instead of Body.
+type queueItem struct {
+	// ID identifies the request and is sent in the Idempotency-Key header so the receiver can detect duplicates.
+	ID          string          `json:"id"`
+	Sequence    uint64          `json:"sequence"`
+	Key         string          `json:"key"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Query       url.Values      `json:"query,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	Enqueued    time.Time       `json:"enqueued"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+func (i *queueItem) fileName() string {
+	return fmt.Sprintf("%020d.json", i.Sequence)
+}
+
+type queue struct {
+	config  QueueConfiguration
+	client  Client
+	logger  log.Logger
+	lock    *sync.Mutex
+	pending map[string][]*queueItem
+	depth   int
+	nextSeq uint64
+	// workers contains the keys that currently have a worker delivering their requests.
+	workers map[string]bool
+	// ctx is the context of the running service, or nil if the queue is not running.
+	ctx context.Context
+	wg  *sync.WaitGroup
+}
+
+func (q *queue) String() string {
+	return "HTTP delivery queue"
+}
+
+func (q *queue) Depth() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.depth
+}
+
+func (q *queue) Enqueue(key string, method string, path string, body interface{}) error {
+	var encodedBody json.RawMessage
+	var query url.Values
+	if hasNoBody(method) && isQueryObject(body) {
+		query = url.Values{}
+		if err := encodeQuery(body, query); err != nil {
+			err = log.Wrap(err, EFailureEncodeFailed, "Failed to encode queued request query")
+			q.logger.Error(err)
+			return err
+		}
+	} else if body != nil {
+		var err error
+		if encodedBody, err = json.Marshal(body); err != nil {
+			err = log.Wrap(err, EFailureEncodeFailed, "Failed to encode queued request body")
+			q.logger.Error(err)
+			return err
+		}
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return log.Wrap(err, EQueuePersistFailed, "Failed to generate request ID")
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := time.Now()
+	item := &queueItem{
+		ID:          hex.EncodeToString(id),
+		Sequence:    q.nextSeq,
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		Query:       query,
+		Body:        encodedBody,
+		Enqueued:    now,
+		NextAttempt: now,
+	}
+	if err := q.save(item); err != nil {
+		err = log.Wrap(err, EQueuePersistFailed, "Failed to persist queued request")
+		q.logger.Error(err)
+		return err
+	}
+	q.nextSeq++
+	q.pending[key] = append(q.pending[key], item)
+	q.depth++
+	q.startWorker(key)
+	return nil
+}
+
+func (q *queue) RunWithLifecycle(lifecycle service.Lifecycle) error {
+	q.lock.Lock()
+	if q.ctx != nil {
+		q.lock.Unlock()
+		return fmt.Errorf("queue is already running")
+	}
+	ctx, cancel := context.WithCancel(lifecycle.Context())
+	defer cancel()
+	q.ctx = ctx
+	for key := range q.pending {
+		q.startWorker(key)
+	}
+	q.lock.Unlock()
+
+	lifecycle.Running()
+	<-ctx.Done()
+
+	// No new workers may be started once waiting for the running ones begins.
+	q.lock.Lock()
+	q.ctx = nil
+	q.lock.Unlock()
+	// In-flight deliveries are limited by the client timeout, so waiting for them is bounded.
+	q.wg.Wait()
+	return nil
+}
+
+// startWorker starts delivering the requests for the key if the queue is running and no worker is active for the
+// key. It must be called with the lock held.
+func (q *queue) startWorker(key string) {
+	if q.ctx == nil || q.workers[key] || len(q.pending[key]) == 0 {
+		return
+	}
+	q.workers[key] = true
+	q.wg.Add(1)
+	go q.work(q.ctx, key)
+}
+
+// work delivers the requests for a single key one after the other, which keeps them in order.
+func (q *queue) work(ctx context.Context, key string) {
+	defer q.wg.Done()
+	for {
+		q.lock.Lock()
+		items := q.pending[key]
+		if len(items) == 0 || ctx.Err() != nil {
+			delete(q.workers, key)
+			q.lock.Unlock()
+			return
+		}
+		item := items[0]
+		q.lock.Unlock()
+
+		if wait := time.Until(item.NextAttempt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+		}
+		q.deliver(item)
+	}
+}
+
+// deliver sends the request once and updates the queue based on the outcome.
+func (q *queue) deliver(item *queueItem) {
+	logger := q.logger.
+		WithLabel("key", item.Key).
+		WithLabel("method", item.Method).
+		WithLabel("path", item.Path).
+		WithLabel("deliveryID", item.ID)
+
+	// The raw body is never query encoded by the client, so it is sent as the body for any method.
+	var body interface{}
+	if item.Body != nil {
+		body = item.Body
+	}
+	statusCode, err := q.client.Request(
+		item.Method,
+		item.Path,
+		body,
+		nil,
+		WithHeader("Idempotency-Key", item.ID),
+		WithQuery(item.Query),
+	)
+	item.Attempts++
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		logger.Debug(log.NewMessage(MQueueDelivered, "Queued request delivered after %d attempts", item.Attempts))
+		q.remove(item, "", logger)
+		return
+	}
+
+	if err == nil {
+		err = fmt.Errorf("unexpected HTTP status code %d", statusCode)
+	}
+	item.LastError = err.Error()
+	permanent := statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+	if permanent || item.Attempts >= q.config.getMaxAttempts() {
+		logger.Error(log.Wrap(
+			err,
+			EQueueDeadLettered,
+			"Queued request moved to the dead letter directory after %d attempts",
+			item.Attempts,
+		))
+		q.remove(item, q.deadDir(), logger)
+		return
+	}
+
+	backoff := q.config.backoff(item.Attempts)
+	item.NextAttempt = time.Now().Add(backoff)
+	logger.Warning(log.Wrap(
+		err,
+		EQueueDeliveryFailed,
+		"Delivery of queued request failed, retrying in %s",
+		backoff,
+	).Label("attempts", item.Attempts))
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.save(item); err != nil {
+		logger.Warning(log.Wrap(err, EQueuePersistFailed, "Failed to persist queued request"))
+	}
+}
+
+// remove takes the item off the queue. If deadDir is not empty, the item is moved there, otherwise it is deleted.
+func (q *queue) remove(item *queueItem, deadDir string, logger log.Logger) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	pendingFile := filepath.Join(q.pendingDir(), item.fileName())
+	var err error
+	if deadDir != "" {
+		if err = q.save(item); err == nil {
+			err = os.Rename(pendingFile, filepath.Join(deadDir, item.fileName()))
+		}
+		if err == nil {
+			err = syncDir(deadDir)
+		}
+	} else {
+		err = os.Remove(pendingFile)
+	}
+	if err != nil {
+		// The request stays on disk and will be delivered again after a restart, which at-least-once allows.
+		logger.Warning(log.Wrap(err, EQueuePersistFailed, "Failed to remove queued request from the queue directory"))
+	}
+	q.pending[item.Key] = q.pending[item.Key][1:]
+	if len(q.pending[item.Key]) == 0 {
+		delete(q.pending, item.Key)
+	}
+	q.depth--
+}
+
+// save writes the item to the pending directory. It must be called with the lock held.
+func (q *queue) save(item *queueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(q.pendingDir(), item.fileName()), data)
+}
+
+// load reads the pending requests from the queue directory.
+func (q *queue) load() error {
+	files, err := ioutil.ReadDir(q.pendingDir())
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(q.pendingDir(), file.Name()))
+		if err != nil {
+			return err
+		}
+		item := &queueItem{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return fmt.Errorf("invalid queue file %s (%w)", file.Name(), err)
+		}
+		q.pending[item.Key] = append(q.pending[item.Key], item)
+		q.depth++
+		if item.Sequence >= q.nextSeq {
+			q.nextSeq = item.Sequence + 1
+		}
+	}
+	// Dead-lettered requests keep their sequence numbers, so new requests must not reuse them.
+	deadFiles, err := ioutil.ReadDir(q.deadDir())
+	if err != nil {
+		return err
+	}
+	for _, file := range deadFiles {
+		var sequence uint64
+		if _, err := fmt.Sscanf(file.Name(), "%020d.json", &sequence); err == nil && sequence >= q.nextSeq {
+			q.nextSeq = sequence + 1
+		}
+	}
+	return nil
+}
+
+func (q *queue) pendingDir() string {
+	return filepath.Join(q.config.Directory, "pending")
+}
+
+func (q *queue) deadDir() string {
+	return filepath.Join(q.config.Directory, "dead")
+}
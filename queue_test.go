@@ -0,0 +1,237 @@
+package http_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerssh/log"
+	"github.com/containerssh/service"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/mock"
+)
+
+type auditEvent struct {
+	Sequence int `json:"sequence"`
+}
+
+func startQueue(t *testing.T, queue http.Queue) func() {
+	ready := make(chan bool, 1)
+	lifecycle := service.NewLifecycle(queue)
+	lifecycle.OnRunning(func(s service.Service, l service.Lifecycle) {
+		ready <- true
+	})
+	errorChannel := make(chan error, 1)
+	go func() {
+		errorChannel <- lifecycle.Run()
+	}()
+	select {
+	case <-ready:
+	case err := <-errorChannel:
+		t.Fatalf("failed to start queue (%v)", err)
+	}
+	return func() {
+		lifecycle.Stop(context.Background())
+		<-errorChannel
+	}
+}
+
+func waitForDepth(t *testing.T, queue http.Queue, depth int) {
+	deadline := time.Now().Add(10 * time.Second)
+	for queue.Depth() != depth {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth did not reach %d (currently %d)", depth, queue.Depth())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	server := mock.Start(t, mock.Config{})
+	server.On("POST", "/session-a", mock.Response{StatusCode: 503}, mock.Response{StatusCode: 204})
+	server.On("POST", "/session-b", mock.Response{StatusCode: 202})
+	server.On("POST", "/rejected", mock.Response{StatusCode: 400})
+
+	logger := log.NewTestLogger(t)
+	client, err := http.NewClient(server.ClientConfiguration(), logger)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	config := http.QueueConfiguration{
+		Directory:      t.TempDir(),
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	}
+	queue, err := http.NewQueue(config, client, logger)
+	if err != nil {
+		t.Fatalf("failed to create queue (%v)", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := queue.Enqueue("a", "POST", "/session-a", auditEvent{Sequence: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Enqueue("b", "POST", "/session-b", auditEvent{Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue("c", "POST", "/rejected", auditEvent{Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, queue.Depth())
+
+	// Requests are persisted and survive a restart before they have been delivered.
+	queue, err = http.NewQueue(config, client, logger)
+	if err != nil {
+		t.Fatalf("failed to reload queue (%v)", err)
+	}
+	assert.Equal(t, 5, queue.Depth())
+
+	stop := startQueue(t, queue)
+	defer stop()
+	waitForDepth(t, queue, 0)
+
+	var sequences []int
+	var deliveryIDs []string
+	for _, request := range server.Requests() {
+		if request.Path != "/session-a" {
+			continue
+		}
+		event := auditEvent{}
+		if err := request.JSON(&event); err != nil {
+			t.Fatal(err)
+		}
+		sequences = append(sequences, event.Sequence)
+		deliveryIDs = append(deliveryIDs, request.Headers.Get("Idempotency-Key"))
+	}
+	// The first request is retried after the 503 and the requests with the same key stay in order.
+	assert.Equal(t, []int{1, 1, 2, 3}, sequences)
+	assert.Equal(t, deliveryIDs[0], deliveryIDs[1])
+	assert.NotEqual(t, deliveryIDs[1], deliveryIDs[2])
+
+	deadLetters, err := ioutil.ReadDir(filepath.Join(config.Directory, "dead"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(deadLetters))
+	pending, err := ioutil.ReadDir(filepath.Join(config.Directory, "pending"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, len(pending))
+}
+
+func TestQueueMaxAttempts(t *testing.T) {
+	server := mock.Start(t, mock.Config{})
+	server.On("POST", "/down", mock.Response{StatusCode: 500})
+
+	logger := log.NewTestLogger(t)
+	client, err := http.NewClient(server.ClientConfiguration(), logger)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	config := http.QueueConfiguration{
+		Directory:      t.TempDir(),
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}
+	queue, err := http.NewQueue(config, client, logger)
+	if err != nil {
+		t.Fatalf("failed to create queue (%v)", err)
+	}
+	stop := startQueue(t, queue)
+	defer stop()
+	if err := queue.Enqueue("a", "POST", "/down", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitForDepth(t, queue, 0)
+	assert.Equal(t, 3, len(server.Requests()))
+
+	deadLetters, err := ioutil.ReadDir(filepath.Join(config.Directory, "dead"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(deadLetters))
+}
+
+func TestQueueRequestEncoding(t *testing.T) {
+	server := mock.Start(t, mock.Config{})
+	logger := log.NewTestLogger(t)
+	config := http.QueueConfiguration{
+		Directory: t.TempDir(),
+	}
+
+	clientConfig := server.ClientConfiguration()
+	clientConfig.RequestEncoding = http.RequestEncodingJSON
+	client, err := http.NewClient(clientConfig, logger)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	_, err = http.NewQueue(config, client, logger)
+	assert.NoError(t, err)
+
+	for _, encoding := range []http.RequestEncoding{
+		http.RequestEncodingWWWURLEncoded,
+		http.RequestEncodingXML,
+		http.RequestEncodingMessagePack,
+	} {
+		t.Run(string(encoding), func(t *testing.T) {
+			clientConfig := server.ClientConfiguration()
+			clientConfig.RequestEncoding = encoding
+			client, err := http.NewClient(clientConfig, logger)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			_, err = http.NewQueue(config, client, logger)
+			assert.Error(t, err)
+		})
+	}
+}
+
+type auditQuery struct {
+	Session string `schema:"session"`
+}
+
+func TestQueueMethodsWithoutBody(t *testing.T) {
+	server := mock.Start(t, mock.Config{})
+	server.On("DELETE", "/session", mock.Response{StatusCode: 204})
+	server.On("GET", "/session", mock.Response{StatusCode: 204})
+
+	logger := log.NewTestLogger(t)
+	client, err := http.NewClient(server.ClientConfiguration(), logger)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	config := http.QueueConfiguration{
+		Directory:      t.TempDir(),
+		InitialBackoff: 10 * time.Millisecond,
+	}
+	queue, err := http.NewQueue(config, client, logger)
+	if err != nil {
+		t.Fatalf("failed to create queue (%v)", err)
+	}
+	if err := queue.Enqueue("a", "DELETE", "/session", map[string]interface{}{"sequence": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue("a", "GET", "/session", &auditQuery{Session: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	stop := startQueue(t, queue)
+	defer stop()
+	waitForDepth(t, queue, 0)
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("unexpected number of requests: %d", len(requests))
+	}
+	event := auditEvent{}
+	if err := requests[0].JSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, event.Sequence)
+	assert.Equal(t, "foo", requests[1].Query.Get("session"))
+	assert.Equal(t, 0, len(requests[1].Body))
+}
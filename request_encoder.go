@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/schema"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// RequestEncoder is an interface to cover all encoders for HTTP request bodies sent by the client. Custom encoders
+// can be added using RegisterRequestEncoder.
+type RequestEncoder interface {
+	// Encode encodes the request body and returns it with the value of the Content-Type header to send. The content
+	// type may differ between requests, for example the multipart encoder uses a new boundary for each request.
+	Encode(body interface{}) (data []byte, contentType string, err error)
+}
+
+var requestEncodersLock = &sync.RWMutex{}
+var requestEncoders = map[RequestEncoding]RequestEncoder{
+	RequestEncodingJSON:          &jsonRequestEncoder{},
+	RequestEncodingWWWURLEncoded: &formRequestEncoder{},
+	RequestEncodingMultipart:     &multipartRequestEncoder{},
+	RequestEncodingXML:           &xmlRequestEncoder{},
+	RequestEncodingYAML:          &yamlRequestEncoder{},
+	RequestEncodingMessagePack:   &msgpackRequestEncoder{},
+	RequestEncodingCBOR:          &cborRequestEncoder{},
+}
+
+// RegisterRequestEncoder registers a custom request encoder under the specified name. The name can then be used as
+// the RequestEncoding in the ClientConfiguration. Registering an encoding that already exists is a bug and panics.
+func RegisterRequestEncoder(encoding RequestEncoding, encoder RequestEncoder) {
+	if encoding == RequestEncodingDefault {
+		panic("BUG: no request encoding name provided to http.RegisterRequestEncoder")
+	}
+	if encoder == nil {
+		panic("BUG: no encoder provided to http.RegisterRequestEncoder")
+	}
+	requestEncodersLock.Lock()
+	defer requestEncodersLock.Unlock()
+	if _, ok := requestEncoders[encoding]; ok {
+		panic(fmt.Errorf("BUG: request encoding %s is already registered", encoding))
+	}
+	requestEncoders[encoding] = encoder
+}
+
+// getRequestEncoder returns the encoder for the request encoding. The default encoding maps to JSON.
+func getRequestEncoder(encoding RequestEncoding) (RequestEncoder, bool) {
+	if encoding == RequestEncodingDefault {
+		encoding = RequestEncodingJSON
+	}
+	requestEncodersLock.RLock()
+	defer requestEncodersLock.RUnlock()
+	encoder, ok := requestEncoders[encoding]
+	return encoder, ok
+}
+
+//region JSON
+
+type jsonRequestEncoder struct {
+}
+
+func (j *jsonRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	buffer := &bytes.Buffer{}
+	if err := json.NewEncoder(buffer).Encode(body); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), "application/json", nil
+}
+
+//endregion
+
+//region WWW-URLENCODED
+
+type formRequestEncoder struct {
+}
+
+func (f *formRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	form := url.Values{}
+	if err := schema.NewEncoder().Encode(body, form); err != nil {
+		return nil, "", err
+	}
+	return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+//endregion
+
+//region Multipart
+
+// MultipartMarshaller is an interface request bodies can implement to write their own parts, such as file uploads,
+// when the multipart request encoding is used. Bodies not implementing this interface are encoded as form fields using
+// their schema tags.
+type MultipartMarshaller interface {
+	MarshalMultipart(writer *multipart.Writer) error
+}
+
+// multipartRequestEncoder encodes each request with a new random boundary.
+type multipartRequestEncoder struct {
+}
+
+func (m *multipartRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	buffer := &bytes.Buffer{}
+	writer := multipart.NewWriter(buffer)
+	if marshaller, ok := body.(MultipartMarshaller); ok {
+		if err := marshaller.MarshalMultipart(writer); err != nil {
+			return nil, "", err
+		}
+	} else {
+		form := url.Values{}
+		if err := schema.NewEncoder().Encode(body, form); err != nil {
+			return nil, "", err
+		}
+		keys := make([]string, 0, len(form))
+		for key := range form {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range form[key] {
+				if err := writer.WriteField(key, value); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), writer.FormDataContentType(), nil
+}
+
+//endregion
+
+//region XML
+
+type xmlRequestEncoder struct {
+}
+
+func (x *xmlRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	data, err := xml.Marshal(body)
+	return data, "application/xml", err
+}
+
+//endregion
+
+//region YAML
+
+type yamlRequestEncoder struct {
+}
+
+func (y *yamlRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	data, err := yaml.Marshal(body)
+	return data, "application/yaml", err
+}
+
+//endregion
+
+//region MessagePack
+
+type msgpackRequestEncoder struct {
+}
+
+func (m *msgpackRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	data, err := msgpack.Marshal(body)
+	return data, "application/msgpack", err
+}
+
+//endregion
+
+//region CBOR
+
+type cborRequestEncoder struct {
+}
+
+func (c *cborRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	data, err := cbor.Marshal(body)
+	return data, "application/cbor", err
+}
+
+//endregion
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+type encoderTestData struct {
+	Message string `json:"message" xml:"message" yaml:"message" msgpack:"message" cbor:"message" schema:"message"`
+}
+
+func TestRequestEncoders(t *testing.T) {
+	decoders := map[RequestEncoding]func(data []byte, contentType string) (encoderTestData, error){
+		RequestEncodingJSON: func(data []byte, _ string) (result encoderTestData, err error) {
+			err = json.Unmarshal(data, &result)
+			return
+		},
+		RequestEncodingWWWURLEncoded: func(data []byte, _ string) (encoderTestData, error) {
+			if string(data) != "message=Hello+world%21" {
+				t.Fatalf("unexpected form body: %s", data)
+			}
+			return encoderTestData{Message: "Hello world!"}, nil
+		},
+		RequestEncodingMultipart: func(data []byte, contentType string) (encoderTestData, error) {
+			_, params, err := mime.ParseMediaType(contentType)
+			if err != nil {
+				return encoderTestData{}, err
+			}
+			form, err := multipart.NewReader(bytes.NewReader(data), params["boundary"]).ReadForm(1024)
+			if err != nil {
+				return encoderTestData{}, err
+			}
+			return encoderTestData{Message: form.Value["message"][0]}, nil
+		},
+		RequestEncodingXML: func(data []byte, _ string) (result encoderTestData, err error) {
+			err = xml.Unmarshal(data, &result)
+			return
+		},
+		RequestEncodingYAML: func(data []byte, _ string) (result encoderTestData, err error) {
+			err = yaml.Unmarshal(data, &result)
+			return
+		},
+		RequestEncodingMessagePack: func(data []byte, _ string) (result encoderTestData, err error) {
+			err = msgpack.Unmarshal(data, &result)
+			return
+		},
+		RequestEncodingCBOR: func(data []byte, _ string) (result encoderTestData, err error) {
+			err = cbor.Unmarshal(data, &result)
+			return
+		},
+	}
+	for encoding, decode := range decoders {
+		t.Run(string(encoding), func(t *testing.T) {
+			encoder, ok := getRequestEncoder(encoding)
+			if !ok {
+				t.Fatalf("encoder not registered")
+			}
+			data, contentType, err := encoder.Encode(&encoderTestData{Message: "Hello world!"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := decode(data, contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != "Hello world!" {
+				t.Fatalf("unexpected decoded message: %s", result.Message)
+			}
+		})
+	}
+}
+
+type customRequestEncoder struct{}
+
+func (c *customRequestEncoder) Encode(body interface{}) ([]byte, string, error) {
+	return []byte(body.(*encoderTestData).Message), "text/plain", nil
+}
+
+func TestCustomRequestEncoder(t *testing.T) {
+	const encoding RequestEncoding = "TEST-PLAIN"
+	if err := encoding.Validate(); err == nil {
+		t.Fatalf("unregistered encoding passed validation")
+	}
+	RegisterRequestEncoder(encoding, &customRequestEncoder{})
+	t.Cleanup(func() {
+		requestEncodersLock.Lock()
+		defer requestEncodersLock.Unlock()
+		delete(requestEncoders, encoding)
+	})
+	if err := encoding.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	encoder, _ := getRequestEncoder(encoding)
+	data, contentType, err := encoder.Encode(&encoderTestData{Message: "Hello world!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Hello world!" {
+		t.Fatalf("unexpected encoded body: %s", data)
+	}
+	if contentType != "text/plain" {
+		t.Fatalf("unexpected content type: %s", contentType)
+	}
+}
+
+func TestMultipartBoundary(t *testing.T) {
+	encoder, _ := getRequestEncoder(RequestEncodingMultipart)
+	boundaries := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		data, contentType, err := encoder.Encode(&encoderTestData{Message: "Hello world!"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "--"+params["boundary"]) {
+			t.Fatalf("the body does not use the boundary of the content type: %s", contentType)
+		}
+		boundaries[params["boundary"]] = true
+	}
+	if len(boundaries) != 2 {
+		t.Fatalf("the multipart boundary was reused between requests")
+	}
+}
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/schema"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// ResponseDecoder is an interface to cover all decoders for HTTP response bodies received by the client. The decoder
+// is selected based on the Content-Type of the response. Custom decoders can be added using RegisterResponseDecoder.
+type ResponseDecoder interface {
+	// ContentTypes returns the MIME types this decoder can decode. They are also advertised in the Accept header.
+	ContentTypes() []string
+	// Decode decodes the response body into the target. If strict is true the decoder should reject fields that are
+	// not present in the target, if the format supports it.
+	Decode(body []byte, target interface{}, strict bool) error
+}
+
+var responseDecodersLock = &sync.RWMutex{}
+var responseDecoders = []ResponseDecoder{
+	&jsonResponseDecoder{},
+	&formResponseDecoder{},
+	&xmlResponseDecoder{},
+	&yamlResponseDecoder{},
+	&msgpackResponseDecoder{},
+	&cborResponseDecoder{},
+}
+
+// RegisterResponseDecoder adds a custom response decoder. Decoders registered later are advertised with a lower
+// preference in the Accept header. Clients only use the decoders that were registered when they were created.
+func RegisterResponseDecoder(decoder ResponseDecoder) {
+	if decoder == nil {
+		panic("BUG: no decoder provided to http.RegisterResponseDecoder")
+	}
+	if len(decoder.ContentTypes()) == 0 {
+		panic("BUG: decoder without content types provided to http.RegisterResponseDecoder")
+	}
+	responseDecodersLock.Lock()
+	defer responseDecodersLock.Unlock()
+	responseDecoders = append(responseDecoders, decoder)
+}
+
+// responseDecoderSet is the set of decoders a client uses, indexed by content type.
+type responseDecoderSet struct {
+	decoders map[string]ResponseDecoder
+	accept   string
+}
+
+// newResponseDecoderSet creates a snapshot of the currently registered decoders and the matching Accept header.
+func newResponseDecoderSet() *responseDecoderSet {
+	responseDecodersLock.RLock()
+	defer responseDecodersLock.RUnlock()
+	set := &responseDecoderSet{
+		decoders: map[string]ResponseDecoder{},
+	}
+	var accept []string
+	for i, decoder := range responseDecoders {
+		q := 10 - i
+		if q < 1 {
+			q = 1
+		}
+		for _, contentType := range decoder.ContentTypes() {
+			contentType = strings.ToLower(contentType)
+			if _, ok := set.decoders[contentType]; ok {
+				continue
+			}
+			set.decoders[contentType] = decoder
+			if q == 10 {
+				accept = append(accept, contentType)
+			} else {
+				accept = append(accept, fmt.Sprintf("%s;q=0.%d", contentType, q))
+			}
+		}
+	}
+	set.accept = strings.Join(accept, ", ")
+	return set
+}
+
+// find returns the decoder for the Content-Type header value. Structured syntax suffixes, such as
+// application/problem+json, are handled by the decoder for the suffix.
+//
+// Responses without a Content-Type or with text/plain are treated as JSON, as are bodies of other unsupported types
+// that are valid JSON. Servers that do not set the Content-Type header, including servers built on older versions of
+// this library, send JSON that Go sniffs as text/plain.
+func (s *responseDecoderSet) find(contentType string, body []byte) (ResponseDecoder, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		contentType = "application/json"
+	}
+	jsonDecoder, hasJSONDecoder := s.decoders["application/json"]
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	if decoder, ok := s.decoders[mediaType]; ok {
+		return decoder, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		if decoder, ok := s.decoders["application/"+mediaType[i+1:]]; ok {
+			return decoder, true
+		}
+	}
+	if hasJSONDecoder && (mediaType == "text/plain" || json.Valid(body)) {
+		return jsonDecoder, true
+	}
+	return nil, false
+}
+
+//region JSON
+
+type jsonResponseDecoder struct {
+}
+
+func (j *jsonResponseDecoder) ContentTypes() []string {
+	return []string{"application/json"}
+}
+
+func (j *jsonResponseDecoder) Decode(body []byte, target interface{}, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(target)
+}
+
+//endregion
+
+//region WWW-URLENCODED
+
+type formResponseDecoder struct {
+}
+
+func (f *formResponseDecoder) ContentTypes() []string {
+	return []string{"application/x-www-form-urlencoded"}
+}
+
+func (f *formResponseDecoder) Decode(body []byte, target interface{}, strict bool) error {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(!strict)
+	return decoder.Decode(target, form)
+}
+
+//endregion
+
+//region XML
+
+type xmlResponseDecoder struct {
+}
+
+func (x *xmlResponseDecoder) ContentTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (x *xmlResponseDecoder) Decode(body []byte, target interface{}, _ bool) error {
+	return xml.Unmarshal(body, target)
+}
+
+//endregion
+
+//region YAML
+
+type yamlResponseDecoder struct {
+}
+
+func (y *yamlResponseDecoder) ContentTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+func (y *yamlResponseDecoder) Decode(body []byte, target interface{}, strict bool) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(strict)
+	return decoder.Decode(target)
+}
+
+//endregion
+
+//region MessagePack
+
+type msgpackResponseDecoder struct {
+}
+
+func (m *msgpackResponseDecoder) ContentTypes() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (m *msgpackResponseDecoder) Decode(body []byte, target interface{}, strict bool) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields(strict)
+	return decoder.Decode(target)
+}
+
+//endregion
+
+//region CBOR
+
+type cborResponseDecoder struct {
+}
+
+func (c *cborResponseDecoder) ContentTypes() []string {
+	return []string{"application/cbor"}
+}
+
+func (c *cborResponseDecoder) Decode(body []byte, target interface{}, strict bool) error {
+	options := cbor.DecOptions{}
+	if strict {
+		options.ExtraReturnErrors = cbor.ExtraDecErrorUnknownField
+	}
+	decMode, err := options.DecMode()
+	if err != nil {
+		return err
+	}
+	return decMode.Unmarshal(body, target)
+}
+
+//endregion
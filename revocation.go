@@ -0,0 +1,318 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containerssh/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	defaultCRLRefreshInterval = time.Hour
+	defaultOCSPTimeout        = 5 * time.Second
+	// maxOCSPResponseSize limits the size of the OCSP response read from the responder.
+	maxOCSPResponseSize = 1024 * 1024
+	// ocspClockSkew is the allowed difference between the clocks of the OCSP responder and this host.
+	ocspClockSkew = 5 * time.Minute
+)
+
+// revocationChecker checks the peer certificates of a TLS connection against CRLs and an OCSP responder. It is used
+// as the VerifyConnection hook of the TLS configuration, which runs after the regular chain verification. Unlike
+// VerifyPeerCertificate, it also runs on resumed sessions, so a revoked certificate cannot resume with a session
+// ticket issued before the revocation.
+type revocationChecker struct {
+	config     RevocationConfiguration
+	logger     log.Logger
+	httpClient *http.Client
+
+	lock       *sync.Mutex
+	crls       []*x509.RevocationList
+	crlsLoaded time.Time
+	crlErr     error
+	// ocspCache contains OCSP responses by issuer and certificate serial number until their next update.
+	ocspCache map[ocspCacheKey]*ocsp.Response
+}
+
+// ocspCacheKey identifies a certificate by its issuer and serial number, since serial numbers are only unique per
+// issuer.
+type ocspCacheKey struct {
+	issuer string
+	serial string
+}
+
+// newRevocationChecker creates a revocation checker. It returns nil if neither CRLs nor OCSP are configured.
+func newRevocationChecker(config RevocationConfiguration, logger log.Logger) *revocationChecker {
+	if !config.enabled() {
+		return nil
+	}
+	checker := &revocationChecker{
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: config.getOCSPTimeout()},
+		lock:       &sync.Mutex{},
+		ocspCache:  map[ocspCacheKey]*ocsp.Response{},
+	}
+	checker.loadCRLs()
+	return checker
+}
+
+// verifyConnection checks all certificates in all verified chains except the roots. Revoked certificates are always
+// rejected. If the revocation status cannot be determined, the connection is rejected in hard-fail mode and allowed
+// with a warning in soft-fail mode.
+func (r *revocationChecker) verifyConnection(state tls.ConnectionState) error {
+	// Peer certificates are not verified if there are no verified chains, for example because the server does not
+	// request client certificates.
+	checked := map[string]bool{}
+	for _, chain := range state.VerifiedChains {
+		for i := 0; i < len(chain)-1; i++ {
+			cert := chain[i]
+			issuer := chain[i+1]
+			// The leaf and shared intermediates appear in several chains, but only need to be checked once per issuer.
+			key := string(cert.Raw) + string(issuer.Raw)
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+			if err := r.check(cert, issuer, i == 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply adds the revocation check to the VerifyConnection hook of the TLS configuration, after the hook that was
+// configured before.
+func (r *revocationChecker) apply(tlsConfig *tls.Config) {
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		return r.verifyConnection(state)
+	}
+}
+
+func (r *revocationChecker) check(cert *x509.Certificate, issuer *x509.Certificate, leaf bool) error {
+	var unknownErr error
+	if len(r.config.CRLFiles) > 0 {
+		revoked, err := r.checkCRL(cert, issuer)
+		if revoked {
+			return r.revoked(cert, "CRL")
+		}
+		if err != nil {
+			unknownErr = err
+		}
+	}
+	if r.config.OCSPResponder != "" && leaf {
+		revoked, err := r.checkOCSP(cert, issuer)
+		if revoked {
+			return r.revoked(cert, "OCSP")
+		}
+		if err != nil {
+			unknownErr = err
+		}
+	}
+	if unknownErr == nil {
+		return nil
+	}
+	if r.config.Mode == RevocationModeSoftFail {
+		r.logger.Warning(log.Wrap(
+			unknownErr,
+			ETLSRevocationSoftFail,
+			"Revocation status of certificate %s could not be determined, allowing connection in soft-fail mode",
+			cert.Subject,
+		).Label("serial", cert.SerialNumber.String()))
+		return nil
+	}
+	err := log.Wrap(
+		unknownErr,
+		ETLSRevocationUnknown,
+		"Revocation status of certificate %s could not be determined",
+		cert.Subject,
+	).Label("serial", cert.SerialNumber.String())
+	r.logger.Error(err)
+	return err
+}
+
+func (r *revocationChecker) revoked(cert *x509.Certificate, source string) error {
+	err := log.NewMessage(
+		ETLSCertificateRevoked,
+		"Certificate %s with serial %s has been revoked according to %s",
+		cert.Subject,
+		cert.SerialNumber,
+		source,
+	).Label("serial", cert.SerialNumber.String())
+	r.logger.Error(err)
+	return err
+}
+
+// checkCRL checks the certificate against the CRL of its issuer. Certificates whose issuer has no configured CRL are
+// not checked. If reloading the CRLs failed, the previously loaded ones are still checked and the error is only
+// returned if they do not list the certificate as revoked.
+func (r *revocationChecker) checkCRL(cert *x509.Certificate, issuer *x509.Certificate) (bool, error) {
+	crls, loadErr := r.getCRLs()
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) || crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+		if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+			return false, fmt.Errorf("the CRL of %s expired at %s", issuer.Subject, crl.NextUpdate)
+		}
+		return false, loadErr
+	}
+	return false, loadErr
+}
+
+// getCRLs returns the loaded CRLs, reloading them from disk if the refresh interval has passed.
+func (r *revocationChecker) getCRLs() ([]*x509.RevocationList, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if time.Since(r.crlsLoaded) >= r.config.getCRLRefreshInterval() {
+		r.loadCRLsLocked()
+	}
+	return r.crls, r.crlErr
+}
+
+func (r *revocationChecker) loadCRLs() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.loadCRLsLocked()
+}
+
+// loadCRLsLocked loads the CRL files. If loading fails, the previously loaded CRLs are kept and the error is
+// reported on the next check, which then fails or soft-fails.
+func (r *revocationChecker) loadCRLsLocked() {
+	r.crlsLoaded = time.Now()
+	var crls []*x509.RevocationList
+	for _, file := range r.config.CRLFiles {
+		loaded, err := loadCRLFile(file)
+		if err != nil {
+			r.crlErr = err
+			return
+		}
+		crls = append(crls, loaded...)
+	}
+	r.crls = crls
+	r.crlErr = nil
+}
+
+// loadCRLFile loads one or more CRLs in PEM format, or a single CRL in DER format.
+func loadCRLFile(file string) ([]*x509.RevocationList, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL file %s (%w)", file, err)
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL file %s (%w)", file, err)
+		}
+		return []*x509.RevocationList{crl}, nil
+	}
+	var result []*x509.RevocationList
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL file %s (%w)", file, err)
+		}
+		result = append(result, crl)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no CRL found in %s", file)
+	}
+	return result, nil
+}
+
+// checkOCSP queries the OCSP responder for the status of the certificate. Responses are cached until their next
+// update time.
+func (r *revocationChecker) checkOCSP(cert *x509.Certificate, issuer *x509.Certificate) (bool, error) {
+	key := ocspCacheKey{issuer: string(issuer.Raw), serial: cert.SerialNumber.String()}
+	r.lock.Lock()
+	response, ok := r.ocspCache[key]
+	r.lock.Unlock()
+	if !ok || response.NextUpdate.IsZero() || time.Now().After(response.NextUpdate) {
+		var err error
+		response, err = r.queryOCSP(cert, issuer)
+		if err != nil {
+			return false, err
+		}
+		if !response.NextUpdate.IsZero() {
+			r.lock.Lock()
+			r.ocspCache[key] = response
+			r.lock.Unlock()
+		}
+	}
+	switch response.Status {
+	case ocsp.Good:
+		return false, nil
+	case ocsp.Revoked:
+		return true, nil
+	default:
+		return false, fmt.Errorf("the OCSP responder does not know the certificate")
+	}
+}
+
+func (r *revocationChecker) queryOCSP(cert *x509.Certificate, issuer *x509.Certificate) (*ocsp.Response, error) {
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request (%w)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.getOCSPTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.OCSPResponder, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request (%w)", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP request failed (%w)", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCSP response (%w)", err)
+	}
+	response, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP response (%w)", err)
+	}
+	// A validly signed but outdated response may be replayed, for example one from before the revocation.
+	now := time.Now()
+	if response.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return nil, fmt.Errorf("the OCSP response is not valid before %s", response.ThisUpdate)
+	}
+	if !response.NextUpdate.IsZero() && now.After(response.NextUpdate.Add(ocspClockSkew)) {
+		return nil, fmt.Errorf("the OCSP response expired at %s", response.NextUpdate)
+	}
+	return response, nil
+}
@@ -0,0 +1,312 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	goHttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+)
+
+type revocationTestEnvironment struct {
+	ca         *certs.CA
+	serverCert *certs.Certificate
+	clientCert *certs.Certificate
+	crlFile    string
+}
+
+func newRevocationTestEnvironment(t *testing.T) *revocationTestEnvironment {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := ca.NewServerCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := ca.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &revocationTestEnvironment{
+		ca:         ca,
+		serverCert: serverCert,
+		clientCert: clientCert,
+		crlFile:    filepath.Join(t.TempDir(), "ca.crl"),
+	}
+}
+
+func (e *revocationTestEnvironment) writeCRL(t *testing.T) {
+	crl, err := e.ca.CRLPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(e.crlFile, []byte(crl), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (e *revocationTestEnvironment) configs() (http.ClientConfiguration, http.ServerConfiguration) {
+	clientConfig, serverConfig := createClientServerConfig()
+	clientConfig.URL = "https://127.0.0.1:8080"
+	clientConfig.CACert = e.ca.CertificatePEM()
+	clientConfig.ClientCert = e.clientCert.CertificatePEM()
+	clientConfig.ClientKey = e.clientCert.PrivateKeyPEM()
+	serverConfig.Cert = e.serverCert.CertificatePEM()
+	serverConfig.Key = e.serverCert.PrivateKeyPEM()
+	serverConfig.ClientCACert = e.ca.CertificatePEM()
+	return clientConfig, serverConfig
+}
+
+// ocspResponder starts a stand-in OCSP responder answering with the given status for every certificate.
+func (e *revocationTestEnvironment) ocspResponder(t *testing.T, status int) *httptest.Server {
+	return e.ocspResponderWithValidity(t, status, -time.Minute, time.Hour)
+}
+
+// ocspResponderWithValidity starts a stand-in OCSP responder whose responses are valid from thisUpdate to nextUpdate,
+// relative to the time of the request.
+func (e *revocationTestEnvironment) ocspResponderWithValidity(
+	t *testing.T,
+	status int,
+	thisUpdate time.Duration,
+	nextUpdate time.Duration,
+) *httptest.Server {
+	server := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, request *goHttp.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			writer.WriteHeader(goHttp.StatusBadRequest)
+			return
+		}
+		ocspRequest, err := ocsp.ParseRequest(body)
+		if err != nil {
+			writer.WriteHeader(goHttp.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		response, err := ocsp.CreateResponse(
+			e.ca.Certificate.Certificate,
+			e.ca.Certificate.Certificate,
+			ocsp.Response{
+				Status:       status,
+				SerialNumber: ocspRequest.SerialNumber,
+				ThisUpdate:   now.Add(thisUpdate),
+				NextUpdate:   now.Add(nextUpdate),
+				RevokedAt:    now.Add(-time.Minute),
+			},
+			e.ca.PrivateKey,
+		)
+		if err != nil {
+			writer.WriteHeader(goHttp.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = writer.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func requestWithConfigs(
+	t *testing.T,
+	clientConfig http.ClientConfiguration,
+	serverConfig http.ServerConfiguration,
+) error {
+	stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	_, err = client.Head("/")
+	return err
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	var typedErr log.Message
+	if !errors.As(err, &typedErr) || typedErr.Code() != code {
+		t.Fatalf("expected an error with code %s, got: %v", code, err)
+	}
+}
+
+func TestRevocationCRL(t *testing.T) {
+	env := newRevocationTestEnvironment(t)
+	env.writeCRL(t)
+
+	t.Run("valid", func(t *testing.T) {
+		clientConfig, serverConfig := env.configs()
+		clientConfig.Revocation.CRLFiles = []string{env.crlFile}
+		serverConfig.Revocation.CRLFiles = []string{env.crlFile}
+		assert.NoError(t, requestWithConfigs(t, clientConfig, serverConfig))
+	})
+
+	t.Run("revoked-server", func(t *testing.T) {
+		env.ca.Revoke(env.serverCert)
+		env.writeCRL(t)
+		clientConfig, serverConfig := env.configs()
+		clientConfig.Revocation.CRLFiles = []string{env.crlFile}
+		assertErrorCode(t, requestWithConfigs(t, clientConfig, serverConfig), http.ETLSCertificateRevoked)
+	})
+
+	t.Run("revoked-client", func(t *testing.T) {
+		env.ca.Revoke(env.clientCert)
+		env.writeCRL(t)
+		clientConfig, serverConfig := env.configs()
+		serverConfig.Revocation.CRLFiles = []string{env.crlFile}
+		assert.Error(t, requestWithConfigs(t, clientConfig, serverConfig))
+	})
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	env := newRevocationTestEnvironment(t)
+	good := env.ocspResponder(t, ocsp.Good)
+	revoked := env.ocspResponder(t, ocsp.Revoked)
+	expired := env.ocspResponderWithValidity(t, ocsp.Good, -2*time.Hour, -time.Hour)
+	notYetValid := env.ocspResponderWithValidity(t, ocsp.Good, time.Hour, 2*time.Hour)
+	skewed := env.ocspResponderWithValidity(t, ocsp.Good, time.Minute, time.Hour)
+	unavailable := httptest.NewServer(goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	for _, testCase := range []struct {
+		name      string
+		responder string
+		mode      http.RevocationMode
+		code      string
+	}{
+		{"good", good.URL, http.RevocationModeHardFail, ""},
+		{"revoked", revoked.URL, http.RevocationModeHardFail, http.ETLSCertificateRevoked},
+		{"revoked-soft", revoked.URL, http.RevocationModeSoftFail, http.ETLSCertificateRevoked},
+		{"unavailable-hard", unavailable.URL, http.RevocationModeHardFail, http.ETLSRevocationUnknown},
+		{"unavailable-soft", unavailable.URL, http.RevocationModeSoftFail, ""},
+		{"expired", expired.URL, http.RevocationModeHardFail, http.ETLSRevocationUnknown},
+		{"not-yet-valid", notYetValid.URL, http.RevocationModeHardFail, http.ETLSRevocationUnknown},
+		{"clock-skew", skewed.URL, http.RevocationModeHardFail, ""},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			clientConfig, serverConfig := env.configs()
+			clientConfig.Revocation.OCSPResponder = testCase.responder
+			clientConfig.Revocation.Mode = testCase.mode
+			err := requestWithConfigs(t, clientConfig, serverConfig)
+			if testCase.code == "" {
+				assert.NoError(t, err)
+			} else {
+				assertErrorCode(t, err, testCase.code)
+			}
+		})
+	}
+
+	t.Run("server", func(t *testing.T) {
+		clientConfig, serverConfig := env.configs()
+		serverConfig.Revocation.OCSPResponder = revoked.URL
+		assert.Error(t, requestWithConfigs(t, clientConfig, serverConfig))
+		serverConfig.Revocation.OCSPResponder = good.URL
+		assert.NoError(t, requestWithConfigs(t, clientConfig, serverConfig))
+	})
+}
+
+func TestRevocationCRLReloadFailure(t *testing.T) {
+	env := newRevocationTestEnvironment(t)
+	env.ca.Revoke(env.serverCert)
+	env.writeCRL(t)
+
+	clientConfig, serverConfig := env.configs()
+	clientConfig.Revocation.CRLFiles = []string{env.crlFile}
+	clientConfig.Revocation.CRLRefreshInterval = time.Millisecond
+	clientConfig.Revocation.Mode = http.RevocationModeSoftFail
+	stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The broken CRL file fails to reload, but the revocation in the previously loaded CRL still applies, even in
+	// soft-fail mode.
+	if err := ioutil.WriteFile(env.crlFile, []byte("not a CRL"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	_, err = client.Head("/")
+	assertErrorCode(t, err, http.ETLSCertificateRevoked)
+}
+
+func TestRevocationSessionResumption(t *testing.T) {
+	env := newRevocationTestEnvironment(t)
+	env.writeCRL(t)
+	_, serverConfig := env.configs()
+	serverConfig.Revocation.CRLFiles = []string{env.crlFile}
+	serverConfig.Revocation.CRLRefreshInterval = time.Millisecond
+	stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// The client of this library does not resume sessions, so a plain client with a session cache is used.
+	clientCert, err := tls.X509KeyPair(
+		[]byte(env.clientCert.CertificatePEM()),
+		[]byte(env.clientCert.PrivateKeyPEM()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(env.ca.Certificate.Certificate)
+	client := &goHttp.Client{
+		Transport: &goHttp.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{
+				RootCAs:            rootCAs,
+				Certificates:       []tls.Certificate{clientCert},
+				ClientSessionCache: tls.NewLRUClientSessionCache(1),
+			},
+		},
+	}
+	request := func() (*goHttp.Response, error) {
+		response, err := client.Get("https://127.0.0.1:8080/")
+		if err != nil {
+			return nil, err
+		}
+		_ = response.Body.Close()
+		return response, nil
+	}
+
+	if _, err := request(); err != nil {
+		t.Fatal(err)
+	}
+	response, err := request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, response.TLS.DidResume)
+
+	env.ca.Revoke(env.clientCert)
+	env.writeCRL(t)
+	time.Sleep(10 * time.Millisecond)
+	_, err = request()
+	assert.Error(t, err)
+}
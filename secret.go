@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretProvider resolves secret references of the form "scheme:reference" in credential fields of the client and
+// server configuration. Custom providers, for example for a secret store, can be added using RegisterSecretProvider.
+type SecretProvider interface {
+	// ResolveSecret returns the value of the secret identified by the reference, which does not include the scheme.
+	// The returned error must not contain the value of the secret.
+	ResolveSecret(reference string) ([]byte, error)
+}
+
+var secretProvidersLock = &sync.RWMutex{}
+var secretProviders = map[string]SecretProvider{
+	"env":    &envSecretProvider{},
+	"file":   &fileSecretProvider{},
+	"base64": &base64SecretProvider{},
+}
+
+// RegisterSecretProvider registers a custom secret provider for references starting with the scheme followed by a
+// colon. Registering a scheme that already exists is a bug and panics.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	if scheme == "" {
+		panic("BUG: no scheme provided to http.RegisterSecretProvider")
+	}
+	if provider == nil {
+		panic("BUG: no provider provided to http.RegisterSecretProvider")
+	}
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+	if _, ok := secretProviders[scheme]; ok {
+		panic(fmt.Errorf("BUG: secret provider %s is already registered", scheme))
+	}
+	secretProviders[scheme] = provider
+}
+
+// resolveSecretReference resolves the spec if it starts with the scheme of a registered secret provider. It returns
+// false if the spec is not a secret reference.
+func resolveSecretReference(spec string) ([]byte, bool, error) {
+	i := strings.Index(spec, ":")
+	if i < 1 {
+		return nil, false, nil
+	}
+	scheme := spec[:i]
+	secretProvidersLock.RLock()
+	provider, ok := secretProviders[scheme]
+	secretProvidersLock.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := provider.ResolveSecret(spec[i+1:])
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to resolve %s secret reference (%w)", scheme, err)
+	}
+	return value, true, nil
+}
+
+// loadSecret resolves the spec if it is a secret reference, otherwise reads the file it names.
+func loadSecret(spec string) ([]byte, error) {
+	value, ok, err := resolveSecretReference(spec)
+	if ok {
+		return value, err
+	}
+	return ioutil.ReadFile(spec)
+}
+
+type envSecretProvider struct {
+}
+
+func (e *envSecretProvider) ResolveSecret(reference string) ([]byte, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return nil, fmt.Errorf("the environment variable %s is not set", reference)
+	}
+	return []byte(value), nil
+}
+
+type fileSecretProvider struct {
+}
+
+func (f *fileSecretProvider) ResolveSecret(reference string) ([]byte, error) {
+	return ioutil.ReadFile(reference)
+}
+
+type base64SecretProvider struct {
+}
+
+func (b *base64SecretProvider) ResolveSecret(reference string) ([]byte, error) {
+	value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(reference))
+	if err != nil {
+		// The decoding error contains the position of the invalid input only, not the input itself.
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,110 @@
+package http_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	goHttp "net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+)
+
+// testVault is a stand-in for a secret store.
+type testVault struct {
+	secrets map[string]string
+}
+
+func (v *testVault) ResolveSecret(reference string) ([]byte, error) {
+	secret, ok := v.secrets[reference]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", reference)
+	}
+	return []byte(secret), nil
+}
+
+func TestSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := ca.NewServerCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := ca.NewClientCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey, err := clientCert.EncryptedPrivateKeyPEM("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCertFile := filepath.Join(dir, "client.crt")
+	if err := ioutil.WriteFile(clientCertFile, []byte(clientCert.CertificatePEM()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HTTP_TEST_CA", ca.CertificatePEM())
+	t.Setenv("HTTP_TEST_PASSPHRASE", "passphrase")
+	http.RegisterSecretProvider("testvault", &testVault{
+		secrets: map[string]string{
+			"server/key": serverCert.PrivateKeyPEM(),
+			"client/key": encryptedKey,
+		},
+	})
+	t.Cleanup(func() {
+		http.UnregisterSecretProvider("testvault")
+	})
+
+	clientConfig, serverConfig := createClientServerConfig()
+	clientConfig.URL = "https://127.0.0.1:8080"
+	clientConfig.CACert = "env:HTTP_TEST_CA"
+	clientConfig.ClientCert = "file:" + clientCertFile
+	clientConfig.ClientKey = "testvault:client/key"
+	clientConfig.KeyPassphrase.Ref = "env:HTTP_TEST_PASSPHRASE"
+	serverConfig.Cert = "base64:" + base64.StdEncoding.EncodeToString([]byte(serverCert.CertificatePEM()))
+	serverConfig.Key = "testvault:server/key"
+	serverConfig.ClientCACert = "env:HTTP_TEST_CA"
+
+	stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	client, err := http.NewClient(clientConfig, log.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Head("/")
+	assert.NoError(t, err)
+
+	// Validation resolves the secrets, but the configuration keeps the references.
+	assert.NoError(t, clientConfig.Validate())
+	assert.NoError(t, serverConfig.Validate())
+	for _, config := range []interface{}{clientConfig, serverConfig} {
+		marshalled, err := json.Marshal(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, strings.Contains(string(marshalled), "-----BEGIN"))
+		assert.False(t, strings.Contains(string(marshalled), `"passphrase"`))
+	}
+
+	t.Run("unresolvable", func(t *testing.T) {
+		config := clientConfig
+		config.ClientKey = "testvault:missing"
+		err := config.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "secret missing not found")
+	})
+}
@@ -34,6 +34,11 @@ func NewServer(
 		if err != nil {
 			return nil, err
 		}
+		if checker := newRevocationChecker(config.Revocation, logger); checker != nil {
+			checker.apply(tlsConfig)
+		}
+		logTLSWarnings(config.tlsWarnings, logger)
+		logNegotiatedTLSVersion(tlsConfig, logger)
 	}
 
 	return &server{
@@ -49,11 +54,13 @@ func NewServer(
 }
 
 func createServerTLSConfig(config ServerConfiguration) (*tls.Config, error) {
+	settings := config.getTLSSettings()
 	tlsConfig := &tls.Config{
-		MinVersion:               config.TLSVersion.getTLSVersion(),
-		CurvePreferences:         config.ECDHCurves.getList(),
+		MinVersion:               settings.minVersion.getTLSVersion(),
+		MaxVersion:               settings.maxVersion.getMaxTLSVersion(),
+		CurvePreferences:         settings.curves.getList(),
 		PreferServerCipherSuites: true,
-		CipherSuites:             config.CipherSuites.getList(),
+		CipherSuites:             settings.cipherSuites.getList(),
 	}
 
 	tlsConfig.Certificates = []tls.Certificate{*config.cert}
@@ -38,6 +38,7 @@ func (s *server) RunWithLifecycle(lifecycle service.Lifecycle) error {
 		Handler:   s.handler,
 		TLSConfig: s.tlsConfig,
 		ErrorLog:  log.New(s.goLogger, "", 0),
+		Protocols: s.config.HTTPVersion.getServerProtocols(s.config.getTLSSettings().supportsHTTP2()),
 	}
 	defer func() {
 		s.lock.Lock()
@@ -54,9 +55,9 @@ func (s *server) RunWithLifecycle(lifecycle service.Lifecycle) error {
 	defer func() { _ = ln.Close() }()
 	var url string
 	if s.srv.TLSConfig != nil {
-		url = fmt.Sprintf("https://%s", s.config.Listen)
+		url = fmt.Sprintf("https://%s", ln.Addr())
 	} else {
-		url = fmt.Sprintf("http://%s", s.config.Listen)
+		url = fmt.Sprintf("http://%s", ln.Addr())
 	}
 	s.onReady(url)
 	lifecycle.Running()
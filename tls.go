@@ -0,0 +1,239 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/containerssh/log"
+)
+
+// tlsSettings are the effective TLS versions, cipher suites and curves of a configuration.
+type tlsSettings struct {
+	minVersion   TLSVersion
+	maxVersion   TLSVersion
+	cipherSuites CipherSuiteList
+	curves       ECDHCurveList
+}
+
+// tlsProfileCurves are the key exchange algorithms of all profiles, preferring the X25519MLKEM768 hybrid post-quantum
+// key exchange with TLS 1.3.
+var tlsProfileCurves = ECDHCurveList{
+	ECDHCurveX25519MLKEM768,
+	ECDHCurveX25519,
+	ECDHCurveSecP256r1,
+	ECDHCurveSecP384r1,
+}
+
+// tlsProfiles contains the settings of the TLS profiles, based on the Mozilla server side TLS recommendations.
+var tlsProfiles = map[TLSProfile]tlsSettings{
+	TLSProfileModern: {
+		minVersion: TLSVersion13,
+		cipherSuites: CipherSuiteList{
+			IANA_TLS_AES_128_GCM_SHA256,
+			IANA_TLS_AES_256_GCM_SHA384,
+			IANA_TLS_CHACHA20_POLY1305_SHA256,
+		},
+		curves: tlsProfileCurves,
+	},
+	TLSProfileIntermediate: {
+		minVersion: TLSVersion12,
+		cipherSuites: CipherSuiteList{
+			IANA_TLS_AES_128_GCM_SHA256,
+			IANA_TLS_AES_256_GCM_SHA384,
+			IANA_TLS_CHACHA20_POLY1305_SHA256,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			IANA_TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			IANA_TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			IANA_TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			IANA_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		curves: tlsProfileCurves,
+	},
+	TLSProfileLegacy: {
+		minVersion: TLSVersion10,
+		cipherSuites: CipherSuiteList{
+			IANA_TLS_AES_128_GCM_SHA256,
+			IANA_TLS_AES_256_GCM_SHA384,
+			IANA_TLS_CHACHA20_POLY1305_SHA256,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			IANA_TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			IANA_TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			IANA_TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			IANA_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			IANA_TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			IANA_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			IANA_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		},
+		curves: tlsProfileCurves,
+	},
+}
+
+// getSettings returns the settings of the profile, overridden by the explicitly configured values that are not
+// empty. If the minimum version is lowered below the one of the profile without configuring cipher suites, the cipher
+// suites of the first profile that allows the minimum version are used, so the lower versions can be negotiated.
+func (p TLSProfile) getSettings(
+	minVersion TLSVersion,
+	maxVersion TLSVersion,
+	cipherSuites CipherSuiteList,
+	curves ECDHCurveList,
+) tlsSettings {
+	settings := tlsProfiles[p.get()]
+	if minVersion != "" {
+		if len(cipherSuites) == 0 && minVersion.getTLSVersion() < settings.minVersion.getTLSVersion() {
+			for _, profile := range []TLSProfile{TLSProfileIntermediate, TLSProfileLegacy} {
+				settings.cipherSuites = tlsProfiles[profile].cipherSuites
+				if tlsProfiles[profile].minVersion.getTLSVersion() <= minVersion.getTLSVersion() {
+					break
+				}
+			}
+		}
+		settings.minVersion = minVersion
+	}
+	settings.maxVersion = maxVersion
+	if len(cipherSuites) > 0 {
+		settings.cipherSuites = cipherSuites
+	}
+	if len(curves) > 0 {
+		settings.curves = curves
+	}
+	return settings
+}
+
+// supportsHTTP2 returns true if the Go HTTP/2 server accepts the settings, which requires an AES-128-GCM cipher suite
+// if TLS 1.2 or older is allowed.
+func (s tlsSettings) supportsHTTP2() bool {
+	if s.minVersion.getTLSVersion() >= tls.VersionTLS13 {
+		return true
+	}
+	for _, suite := range s.cipherSuites.getList() {
+		if suite == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || suite == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
+
+// warnings returns a description of each combination of TLS versions, cipher suites and certificate key that keeps
+// some or all connections from being negotiated. The certificate may be nil if it is not known, for example on the
+// client.
+func (s tlsSettings) warnings(certificate *tls.Certificate) []string {
+	minVersion := s.minVersion.getTLSVersion()
+	maxVersion := s.maxVersion.getMaxTLSVersion()
+	if maxVersion == 0 {
+		maxVersion = tls.VersionTLS13
+	}
+	suiteVersions := map[uint16][]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suiteVersions[suite.ID] = suite.SupportedVersions
+	}
+	supports := func(suite uint16, version uint16) bool {
+		for _, supportedVersion := range suiteVersions[suite] {
+			if supportedVersion == version {
+				return true
+			}
+		}
+		return false
+	}
+
+	var warnings []string
+	var unsupportedVersions []string
+	var legacySuites []uint16
+	for version := minVersion; version <= maxVersion; version++ {
+		found := false
+		for _, suite := range s.cipherSuites.getList() {
+			if supports(suite, version) {
+				found = true
+				if version < tls.VersionTLS13 {
+					legacySuites = append(legacySuites, suite)
+				}
+			}
+		}
+		if !found {
+			unsupportedVersions = append(unsupportedVersions, tls.VersionName(version))
+		}
+	}
+	if len(unsupportedVersions) > 0 {
+		message := fmt.Sprintf(
+			"none of the configured cipher suites can be used with %s",
+			strings.Join(unsupportedVersions, ", "),
+		)
+		if unsupportedVersions[len(unsupportedVersions)-1] == tls.VersionName(tls.VersionTLS13) {
+			message += ", TLS 1.3 connections use the built-in TLS 1.3 cipher suites instead"
+		}
+		warnings = append(warnings, message)
+	}
+
+	if certificate != nil && len(legacySuites) > 0 {
+		keyType, suiteMarker := "", ""
+		switch certificate.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			keyType, suiteMarker = "RSA", "_RSA_"
+		case *ecdsa.PrivateKey, ed25519.PrivateKey:
+			keyType, suiteMarker = "ECDSA or Ed25519", "_ECDSA_"
+		}
+		found := keyType == ""
+		for _, suite := range legacySuites {
+			if strings.Contains(tls.CipherSuiteName(suite), suiteMarker) {
+				found = true
+			}
+		}
+		if !found {
+			warnings = append(warnings, fmt.Sprintf(
+				"the certificate has an %s key, but none of the cipher suites for TLS 1.2 and older supports it, "+
+					"only TLS 1.3 connections can be negotiated",
+				keyType,
+			))
+		}
+	}
+	return warnings
+}
+
+// logTLSWarnings logs the warnings found when validating the configuration.
+func logTLSWarnings(warnings []string, logger log.Logger) {
+	for _, warning := range warnings {
+		logger.Warning(log.NewMessage(ETLSConfigurationMismatch, "TLS configuration problem: %s", warning))
+	}
+}
+
+// logNegotiatedTLSVersion logs the TLS version and cipher suite of each connection made with the TLS configuration
+// after it has been verified. Insecure versions are logged as warnings, everything else at the debug level.
+func logNegotiatedTLSVersion(tlsConfig *tls.Config, logger log.Logger) {
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		version := tls.VersionName(state.Version)
+		cipherSuite := tls.CipherSuiteName(state.CipherSuite)
+		if state.Version < tls.VersionTLS12 {
+			logger.Warning(log.NewMessage(
+				ETLSInsecureVersionNegotiated,
+				"Insecure TLS version %s negotiated with cipher suite %s",
+				version,
+				cipherSuite,
+			).Label("tlsVersion", version).Label("cipherSuite", cipherSuite))
+			return nil
+		}
+		keyExchange := negotiatedKeyExchange(state)
+		logger.Debug(log.NewMessage(
+			MTLSVersionNegotiated,
+			"%s negotiated with cipher suite %s and key exchange %s",
+			version,
+			cipherSuite,
+			keyExchange,
+		).
+			Label("tlsVersion", version).
+			Label("cipherSuite", cipherSuite).
+			Label("keyExchange", keyExchange))
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.26
+
+package http_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/containerssh/http"
+)
+
+func TestHybridKeyExchange(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "using X25519MLKEM768")
+	})
+
+	for _, curve := range []http.ECDHCurve{
+		http.ECDHCurveX25519MLKEM768,
+		http.ECDHCurveSecP256r1MLKEM768,
+		http.ECDHCurveSecP384r1MLKEM1024,
+	} {
+		t.Run(string(curve), func(t *testing.T) {
+			clientConfig, serverConfig := createTLSClientServerConfig(t)
+			clientConfig.ECDHCurves = http.ECDHCurveList{curve, http.ECDHCurveX25519}
+			serverConfig.ECDHCurves = http.ECDHCurveList{curve}
+			result := negotiateTLS(t, clientConfig, serverConfig)
+			assert.Equal(t, http.HealthStatusPassed, result.Status)
+			assert.Contains(t, result.Detail, "TLS 1.3")
+			assert.True(t, strings.EqualFold(result.Detail[strings.LastIndex(result.Detail, " ")+1:], string(curve)))
+		})
+	}
+
+	t.Run("unmarshal", func(t *testing.T) {
+		expected := http.ECDHCurveList{http.ECDHCurveX25519MLKEM768Alt, http.ECDHCurveSecP384r1MLKEM1024}
+
+		jsonConfig := http.ClientConfiguration{}
+		if err := json.Unmarshal([]byte(`{"curves":"X25519MLKEM768:secp384r1mlkem1024"}`), &jsonConfig); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, jsonConfig.ECDHCurves)
+		assert.NoError(t, jsonConfig.ECDHCurves.Validate())
+
+		yamlConfig := http.ServerConfiguration{}
+		if err := yaml.Unmarshal([]byte("curves:\n  - X25519MLKEM768\n  - secp384r1mlkem1024\n"), &yamlConfig); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, yamlConfig.ECDHCurves)
+		assert.NoError(t, yamlConfig.ECDHCurves.Validate())
+
+		assert.Error(t, http.ECDHCurveList{"mlkem512"}.Validate())
+	})
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/containerssh/http/certs"
+)
+
+func TestTLSSettingsWarnings(t *testing.T) {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaCert, err := ca.NewServerCert(certs.WithKeyType(certs.KeyTypeRSA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate := &tls.Certificate{PrivateKey: rsaCert.PrivateKey}
+
+	for name, test := range map[string]struct {
+		settings tlsSettings
+		expected []string
+	}{
+		"modern": {
+			settings: TLSProfileModern.getSettings("", "", nil, nil),
+		},
+		"intermediate": {
+			settings: TLSProfileIntermediate.getSettings("", "", nil, nil),
+		},
+		"lowered-minimum": {
+			settings: TLSProfileModern.getSettings(TLSVersion12, "", nil, nil),
+		},
+		"no-tls-1.2-suite": {
+			settings: TLSProfileModern.getSettings(TLSVersion12, "", CipherSuiteList{IANA_TLS_AES_128_GCM_SHA256}, nil),
+			expected: []string{"TLS 1.2"},
+		},
+		"no-tls-1.3-suite": {
+			settings: TLSProfileModern.getSettings(
+				"",
+				"",
+				CipherSuiteList{IANA_TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				nil,
+			),
+			expected: []string{"TLS 1.3"},
+		},
+		"key-type": {
+			settings: TLSProfileIntermediate.getSettings(
+				"",
+				"",
+				CipherSuiteList{IANA_TLS_AES_128_GCM_SHA256, IANA_TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+				nil,
+			),
+			expected: []string{"RSA key"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			warnings := test.settings.warnings(certificate)
+			if len(warnings) != len(test.expected) {
+				t.Fatalf("unexpected warnings: %v", warnings)
+			}
+			for i, expected := range test.expected {
+				if !strings.Contains(warnings[i], expected) {
+					t.Fatalf("unexpected warning: %s, expected: %s", warnings[i], expected)
+				}
+			}
+		})
+	}
+}
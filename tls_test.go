@@ -0,0 +1,319 @@
+package http_test
+
+import (
+	"context"
+	goHttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containerssh/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/containerssh/http"
+	"github.com/containerssh/http/certs"
+)
+
+func createTLSClientServerConfig(t *testing.T) (http.ClientConfiguration, http.ServerConfiguration) {
+	ca, err := certs.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := ca.NewServerCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, serverConfig := createClientServerConfig()
+	clientConfig.URL = "https://127.0.0.1:8080"
+	clientConfig.CACert = ca.CertificatePEM()
+	clientConfig.HealthCheck.Path = "/"
+	serverConfig.Cert = serverCert.CertificatePEM()
+	serverConfig.Key = serverCert.PrivateKeyPEM()
+	return clientConfig, serverConfig
+}
+
+// negotiateTLS runs a health check against a server with the given configuration and returns the result of the TLS
+// stage.
+func negotiateTLS(
+	t *testing.T,
+	clientConfig http.ClientConfiguration,
+	serverConfig http.ServerConfiguration,
+) http.HealthStageResult {
+	return negotiateTLSWithLogger(t, clientConfig, serverConfig, log.NewTestLogger(t))
+}
+
+// negotiateTLSWithLogger is identical to negotiateTLS, but creates the client with the given logger.
+func negotiateTLSWithLogger(
+	t *testing.T,
+	clientConfig http.ClientConfiguration,
+	serverConfig http.ServerConfiguration,
+	logger log.Logger,
+) http.HealthStageResult {
+	stop, err := startServer(t, serverConfig, goHttp.HandlerFunc(func(writer goHttp.ResponseWriter, _ *goHttp.Request) {
+		writer.WriteHeader(goHttp.StatusNoContent)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	client, err := http.NewClient(clientConfig, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := client.HealthCheck(context.Background())
+	for _, stage := range report.Stages {
+		if stage.Stage == http.HealthStageTLS {
+			return stage
+		}
+	}
+	t.Fatal("no TLS stage in health check report")
+	return http.HealthStageResult{}
+}
+
+// codeRecordingLogger passes messages to the backend logger and records the codes of the logged messages.
+type codeRecordingLogger struct {
+	log.Logger
+	lock  *sync.Mutex
+	codes *[]string
+}
+
+func newCodeRecordingLogger(t *testing.T) *codeRecordingLogger {
+	return &codeRecordingLogger{
+		Logger: log.NewTestLogger(t),
+		lock:   &sync.Mutex{},
+		codes:  &[]string{},
+	}
+}
+
+func (l *codeRecordingLogger) record(messages []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, message := range messages {
+		if msg, ok := message.(log.Message); ok {
+			*l.codes = append(*l.codes, msg.Code())
+		}
+	}
+}
+
+// Codes returns the codes of the messages logged so far.
+func (l *codeRecordingLogger) Codes() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string(nil), *l.codes...)
+}
+
+func (l *codeRecordingLogger) WithLevel(level log.Level) log.Logger {
+	return &codeRecordingLogger{Logger: l.Logger.WithLevel(level), lock: l.lock, codes: l.codes}
+}
+
+func (l *codeRecordingLogger) WithLabel(labelName log.LabelName, labelValue log.LabelValue) log.Logger {
+	return &codeRecordingLogger{Logger: l.Logger.WithLabel(labelName, labelValue), lock: l.lock, codes: l.codes}
+}
+
+func (l *codeRecordingLogger) Debug(message ...interface{}) {
+	l.record(message)
+	l.Logger.Debug(message...)
+}
+
+func (l *codeRecordingLogger) Info(message ...interface{}) {
+	l.record(message)
+	l.Logger.Info(message...)
+}
+
+func (l *codeRecordingLogger) Notice(message ...interface{}) {
+	l.record(message)
+	l.Logger.Notice(message...)
+}
+
+func (l *codeRecordingLogger) Warning(message ...interface{}) {
+	l.record(message)
+	l.Logger.Warning(message...)
+}
+
+func (l *codeRecordingLogger) Error(message ...interface{}) {
+	l.record(message)
+	l.Logger.Error(message...)
+}
+
+func (l *codeRecordingLogger) Critical(message ...interface{}) {
+	l.record(message)
+	l.Logger.Critical(message...)
+}
+
+func TestTLSVersions(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.TLSVersion = http.TLSVersion12
+		logger := newCodeRecordingLogger(t)
+		result := negotiateTLSWithLogger(t, clientConfig, serverConfig, logger)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.3")
+		assert.Contains(t, logger.Codes(), http.MTLSVersionNegotiated)
+		assert.NotContains(t, logger.Codes(), http.ETLSInsecureVersionNegotiated)
+	})
+
+	t.Run("max-version", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.TLSVersion = http.TLSVersion12
+		serverConfig.TLSVersion = http.TLSVersion12
+		serverConfig.MaxTLSVersion = http.TLSVersion12
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.2")
+	})
+
+	t.Run("min-version", func(t *testing.T) {
+		// The client requires TLS 1.3 by default, which the server does not offer.
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		serverConfig.TLSVersion = http.TLSVersion12
+		serverConfig.MaxTLSVersion = http.TLSVersion12
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusFailed, result.Status)
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		serverConfig.TLSVersion = http.TLSVersion10
+		serverConfig.MaxTLSVersion = http.TLSVersion11
+		serverConfig.CipherSuites = http.CipherSuiteList{http.IANA_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+		clientConfig.TLSVersion = http.TLSVersion10
+		clientConfig.CipherSuites = http.CipherSuiteList{http.IANA_TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+
+		assert.Error(t, serverConfig.Validate())
+		assert.Error(t, clientConfig.Validate())
+
+		serverConfig.AllowInsecureTLSVersions = true
+		clientConfig.AllowInsecureTLSVersions = true
+		logger := newCodeRecordingLogger(t)
+		result := negotiateTLSWithLogger(t, clientConfig, serverConfig, logger)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.1")
+		assert.Contains(t, logger.Codes(), http.ETLSInsecureVersionNegotiated)
+		assert.NotContains(t, logger.Codes(), http.MTLSVersionNegotiated)
+	})
+
+	t.Run("invalid-range", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.MaxTLSVersion = http.TLSVersion12
+		serverConfig.MaxTLSVersion = http.TLSVersion12
+		assert.Error(t, clientConfig.Validate())
+		assert.Error(t, serverConfig.Validate())
+	})
+}
+
+func TestTLSProfiles(t *testing.T) {
+	t.Run("modern", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.TLSProfile = http.TLSProfileIntermediate
+		clientConfig.MaxTLSVersion = http.TLSVersion12
+		serverConfig.TLSProfile = http.TLSProfileModern
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusFailed, result.Status)
+
+		clientConfig.MaxTLSVersion = ""
+		result = negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.3")
+	})
+
+	t.Run("intermediate", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.TLSProfile = http.TLSProfileIntermediate
+		clientConfig.MaxTLSVersion = http.TLSVersion12
+		serverConfig.TLSProfile = http.TLSProfileIntermediate
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.2 with TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+	})
+
+	t.Run("override", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		clientConfig.TLSProfile = http.TLSProfileIntermediate
+		clientConfig.MaxTLSVersion = http.TLSVersion12
+		serverConfig.TLSProfile = http.TLSProfileIntermediate
+		serverConfig.CipherSuites = http.CipherSuiteList{
+			http.IANA_TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			http.IANA_TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		}
+		clientConfig.CipherSuites = http.CipherSuiteList{http.IANA_TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+		assert.Contains(t, result.Detail, "TLS 1.2 with TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384")
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		clientConfig, _ := createTLSClientServerConfig(t)
+		clientConfig.TLSProfile = http.TLSProfileLegacy
+		assert.Error(t, clientConfig.Validate())
+		clientConfig.AllowInsecureTLSVersions = true
+		assert.NoError(t, clientConfig.Validate())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		clientConfig, _ := createTLSClientServerConfig(t)
+		clientConfig.TLSProfile = "paranoid"
+		assert.Error(t, clientConfig.Validate())
+	})
+
+	t.Run("http2", func(t *testing.T) {
+		clientConfig, serverConfig := createTLSClientServerConfig(t)
+		serverConfig.TLSProfile = http.TLSProfileIntermediate
+		serverConfig.CipherSuites = http.CipherSuiteList{http.IANA_TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+
+		// Without an explicit HTTP version, the server falls back to HTTP/1.1.
+		assert.NoError(t, serverConfig.Validate())
+		assert.Contains(t, strings.Join(serverConfig.TLSWarnings(), "\n"), "HTTP/2 is disabled")
+		result := negotiateTLS(t, clientConfig, serverConfig)
+		assert.Equal(t, http.HealthStatusPassed, result.Status)
+
+		serverConfig.HTTPVersion = http.HTTPVersion2
+		err := serverConfig.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "HTTP/2")
+
+		serverConfig.HTTPVersion = http.HTTPVersion11
+		assert.NoError(t, serverConfig.Validate())
+		assert.NotContains(t, strings.Join(serverConfig.TLSWarnings(), "\n"), "HTTP/2 is disabled")
+	})
+}
+
+func TestTLSWarnings(t *testing.T) {
+	clientConfig, serverConfig := createTLSClientServerConfig(t)
+	assert.NoError(t, clientConfig.Validate())
+	assert.Empty(t, clientConfig.TLSWarnings())
+	assert.NoError(t, serverConfig.Validate())
+	assert.Empty(t, serverConfig.TLSWarnings())
+
+	t.Run("version", func(t *testing.T) {
+		config := clientConfig
+		config.TLSVersion = http.TLSVersion12
+		config.CipherSuites = http.CipherSuiteList{http.IANA_TLS_AES_128_GCM_SHA256}
+		assert.NoError(t, config.Validate())
+		if assert.Len(t, config.TLSWarnings(), 1) {
+			assert.Contains(t, config.TLSWarnings()[0], "TLS 1.2")
+		}
+	})
+
+	t.Run("key-type", func(t *testing.T) {
+		ca, err := certs.NewCA()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaCert, err := ca.NewServerCert(certs.WithKeyType(certs.KeyTypeRSA))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := serverConfig
+		config.Cert = rsaCert.CertificatePEM()
+		config.Key = rsaCert.PrivateKeyPEM()
+		config.TLSProfile = http.TLSProfileIntermediate
+		config.CipherSuites = http.CipherSuiteList{
+			http.IANA_TLS_AES_128_GCM_SHA256,
+			http.IANA_TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		}
+		assert.NoError(t, config.Validate())
+		if assert.Len(t, config.TLSWarnings(), 1) {
+			assert.Contains(t, config.TLSWarnings()[0], "RSA key")
+		}
+	})
+}
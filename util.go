@@ -2,12 +2,64 @@ package http
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
+// loadPem returns the PEM given inline in the spec, or loads it from the secret reference or file name in the spec.
 func loadPem(spec string) ([]byte, error) {
-	if !strings.HasPrefix(strings.TrimSpace(spec), "-----") {
-		return ioutil.ReadFile(spec)
+	if isInlinePem(spec) {
+		return []byte(spec), nil
 	}
-	return []byte(spec), nil
+	return loadSecret(spec)
+}
+
+// isInlinePem returns true if the spec passed to loadPem is the PEM itself rather than a file name or secret
+// reference.
+func isInlinePem(spec string) bool {
+	return strings.HasPrefix(strings.TrimSpace(spec), "-----")
+}
+
+// writeFileAtomic writes the data to a temporary file and moves it in place, so the file is never partially written.
+// The file and the directory entry are synced to disk, so the file survives a crash of the host once this function
+// returns. The file is only readable by the owner.
+func writeFileAtomic(file string, data []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, file); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return syncDir(filepath.Dir(file))
+}
+
+// syncDir syncs the directory to disk, which persists the renames and removals of files in it.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
 }